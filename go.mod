@@ -11,7 +11,6 @@ require (
 	github.com/golang/protobuf v1.3.5
 	github.com/google/uuid v1.0.0
 	github.com/gorilla/websocket v0.0.0-20170926233335-4201258b820c
-	github.com/pquerna/ffjson v0.0.0-20190930134022-aa0246cd15f7
 	github.com/qiangxue/fasthttp-routing v0.0.0-20160225050629-6ccdc2a18d87
 	github.com/stretchr/testify v1.5.1 // indirect
 	github.com/valyala/fasthttp v1.9.0
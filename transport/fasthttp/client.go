@@ -12,20 +12,33 @@ import (
 	"github.com/valyala/fasthttp"
 )
 
+// FastHTTPClient is the backend of a Client, which sends the requests and
+// reads their responses. It is implemented by fasthttp.Client, HostClient,
+// LBClient and PipelineClient, so that connections can be pooled and
+// balanced per upstream, and may be faked in tests.
+//
+// Backends which also implement DoDeadline, as those of fasthttp do, are
+// given the deadline of each request. The requests of other backends are
+// given up on at their deadline, but run to completion.
 type FastHTTPClient interface {
 	Do(req *fasthttp.Request, resp *fasthttp.Response) error
 }
 
+type deadlineClient interface {
+	DoDeadline(req *fasthttp.Request, resp *fasthttp.Response, deadline time.Time) error
+}
+
 // Client wraps a URL and provides a method that implements endpoint.Endpoint.
 type Client struct {
-	client  *fasthttp.Client
-	method  string
-	tgt     *url.URL
-	timeout time.Duration
-	enc     EncodeRequestFunc
-	dec     DecodeResponseFunc
-	before  []ClientRequestFunc
-	after   []ClientResponseFunc
+	client    FastHTTPClient
+	method    string
+	tgt       *url.URL
+	timeout   time.Duration
+	enc       EncodeRequestFunc
+	dec       DecodeResponseFunc
+	before    []ClientRequestFunc
+	after     []ClientResponseFunc
+	finalizer []ClientFinalizerFunc
 }
 
 // NewClient constructs a usable Client for a single remote method.
@@ -54,13 +67,15 @@ func NewClient(
 // ClientOption sets an optional parameter for clients.
 type ClientOption func(*Client)
 
-// SetClient sets the underlying HTTP client used for requests.
-// By default, http.DefaultClient is used.
-func SetClient(client *fasthttp.Client) ClientOption {
+// SetClient sets the backend used for requests.
+// By default, a zero fasthttp.Client is used.
+func SetClient(client FastHTTPClient) ClientOption {
 	return func(c *Client) { c.client = client }
 }
 
-// SetClientTimeout sets the client request timeout .
+// SetClientTimeout sets the maximum duration of a request, from sending it
+// to reading its response. Requests of a context with an earlier deadline
+// end at that deadline. By default, requests end only with their context.
 func SetClientTimeout(timeout time.Duration) ClientOption {
 	return func(c *Client) { c.timeout = timeout }
 }
@@ -78,23 +93,58 @@ func ClientAfter(after ...ClientResponseFunc) ClientOption {
 	return func(c *Client) { c.after = append(c.after, after...) }
 }
 
+// ClientFinalizer is executed at the end of every HTTP request.
+// By default, no finalizer is registered.
+func ClientFinalizer(f ...ClientFinalizerFunc) ClientOption {
+	return func(c *Client) { c.finalizer = append(c.finalizer, f...) }
+}
+
 // Endpoint returns a usable endpoint that invokes the remote endpoint.
+//
+// The request is given up when ctx is done, or when its deadline, the
+// earliest of the deadline of ctx and the timeout of the client, is reached.
+// The endpoint then returns the error of ctx, or context.DeadlineExceeded
+// if the client timed out.
 func (c Client) Endpoint() endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		ctx, cancel := context.WithCancel(ctx)
 		defer cancel()
 
 		var (
-			err error
+			err       error
+			responded bool
+			size      int64 // taken before decoders may take the body over
 		)
 
 		req := fasthttp.AcquireRequest()
+		resp := fasthttp.AcquireResponse()
+		// Requests given up on still use req and resp, which are
+		// released once they are done.
+		inFlight := false
+		defer func() {
+			if !inFlight {
+				fasthttp.ReleaseRequest(req)
+				fasthttp.ReleaseResponse(resp)
+			}
+		}()
+
+		if len(c.finalizer) > 0 {
+			begin := time.Now()
+			defer func() {
+				if responded {
+					ctx = context.WithValue(ctx, ContextKeyResponseHeaders, &resp.Header)
+					ctx = context.WithValue(ctx, ContextKeyResponseSize, size)
+					ctx = context.WithValue(ctx, ContextKeyResponseStatusCode, resp.StatusCode())
+				}
+				ctx = context.WithValue(ctx, ContextKeyResponseDuration, time.Since(begin))
+				for _, f := range c.finalizer {
+					f(ctx, err)
+				}
+			}()
+		}
+
 		req.SetRequestURI(c.tgt.String())
 		req.Header.SetMethod(strings.ToUpper(c.method))
-		defer fasthttp.ReleaseRequest(req)
-
-		resp := fasthttp.AcquireResponse()
-		defer fasthttp.ReleaseResponse(resp)
 
 		if err = c.enc(ctx, req, request); err != nil {
 			return nil, err
@@ -104,11 +154,12 @@ func (c Client) Endpoint() endpoint.Endpoint {
 			ctx = f(ctx, req)
 		}
 
-		err = c.client.Do(req, resp)
+		inFlight, err = c.do(ctx, req, resp)
 
 		if err != nil {
 			return nil, err
 		}
+		responded, size = true, responseSize(resp)
 
 		for _, f := range c.after {
 			ctx = f(ctx, resp)
@@ -123,6 +174,61 @@ func (c Client) Endpoint() endpoint.Endpoint {
 	}
 }
 
+// do sends req and reads resp, until the deadline of the request, the
+// earliest of the deadline of ctx and the timeout of the client.
+func (c Client) do(ctx context.Context, req *fasthttp.Request, resp *fasthttp.Response) (inFlight bool, err error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	return DoContext(ctx, c.client, req, resp)
+}
+
+// DoContext sends req with client and reads resp, giving up when ctx is done.
+// The deadline of ctx is handed to clients that implement DoDeadline, as the
+// clients of fasthttp do. It reports whether the request was given up on
+// while in flight, in which case req and resp must no longer be used by the
+// caller: they are released once the request is done.
+func DoContext(ctx context.Context, client FastHTTPClient, req *fasthttp.Request, resp *fasthttp.Response) (inFlight bool, err error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	deadline, hasDeadline := ctx.Deadline()
+	errc := make(chan error, 1)
+	go func() {
+		if dc, ok := client.(deadlineClient); ok && hasDeadline {
+			errc <- dc.DoDeadline(req, resp, deadline)
+			return
+		}
+		errc <- client.Do(req, resp)
+	}()
+
+	select {
+	case err := <-errc:
+		if err == fasthttp.ErrTimeout {
+			err = context.DeadlineExceeded
+		}
+		return false, err
+	case <-ctx.Done():
+		go func() {
+			<-errc
+			fasthttp.ReleaseRequest(req)
+			fasthttp.ReleaseResponse(resp)
+		}()
+		return true, ctx.Err()
+	}
+}
+
+// ClientFinalizerFunc can be used to perform work at the end of a client HTTP
+// request, after the response is returned, like access logging and metrics.
+// It is always run, with the error returned by the endpoint, which may be nil.
+// Additional response parameters are provided in the context under keys with
+// the ContextKeyResponse prefix; there may be none, depending on when an
+// error occurs.
+type ClientFinalizerFunc func(ctx context.Context, err error)
+
 // EncodeJSONRequest is an EncodeRequestFunc that serializes the request as a
 // JSON object to the Request body. Many JSON-over-HTTP services can use it as
 // a sensible default. If the request implements Headerer, the provided headers
@@ -2,6 +2,8 @@ package fasthttp_test
 
 import (
 	"context"
+	"errors"
+	"net"
 	"net/http"
 	"net/url"
 	"testing"
@@ -9,6 +11,7 @@ import (
 
 	httptransport "github.com/l-vitaly/go-kit/transport/fasthttp"
 	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
 )
 
 type TestResponse struct {
@@ -34,8 +37,14 @@ func TestHTTPClient(t *testing.T) {
 		}
 	)
 
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
 	go func() {
-		fasthttp.ListenAndServe(":9000", func(rctx *fasthttp.RequestCtx) {
+		fasthttp.Serve(ln, func(rctx *fasthttp.RequestCtx) {
 			headers <- string(rctx.Request.Header.Peek(headerKey))
 			rctx.Response.Header.Set(afterHeaderKey, afterHeaderVal)
 			rctx.SetStatusCode(http.StatusOK)
@@ -45,7 +54,7 @@ func TestHTTPClient(t *testing.T) {
 
 	client := httptransport.NewClient(
 		"GET",
-		mustParse("http://localhost:9000"),
+		mustParse("http://"+ln.Addr().String()),
 		encode,
 		decode,
 		httptransport.ClientBefore(httptransport.SetRequestHeader(headerKey, headerVal)),
@@ -87,8 +96,14 @@ func TestEncodeJSONRequest(t *testing.T) {
 	var header *fasthttp.RequestHeader
 	var body string
 
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
 	go func() {
-		fasthttp.ListenAndServe(":9001", func(rctx *fasthttp.RequestCtx) {
+		fasthttp.Serve(ln, func(rctx *fasthttp.RequestCtx) {
 			header = &rctx.Request.Header
 			body = string(rctx.Request.Body())
 		})
@@ -96,7 +111,7 @@ func TestEncodeJSONRequest(t *testing.T) {
 
 	client := httptransport.NewClient(
 		"POST",
-		mustParse("http://localhost:9001"),
+		mustParse("http://"+ln.Addr().String()),
 		httptransport.EncodeJSONRequest,
 		func(context.Context, *fasthttp.Response) (interface{}, error) { return nil, nil },
 	).Endpoint()
@@ -130,6 +145,130 @@ func TestEncodeJSONRequest(t *testing.T) {
 	}
 }
 
+func TestClientTimeout(t *testing.T) {
+	ln := fasthttputil.NewInmemoryListener()
+	defer ln.Close()
+
+	release := make(chan struct{})
+	defer close(release)
+	go fasthttp.Serve(ln, func(rctx *fasthttp.RequestCtx) {
+		if string(rctx.Path()) == "/slow" {
+			<-release
+		}
+		rctx.SetStatusCode(http.StatusOK)
+	})
+
+	newClient := func(path string, options ...httptransport.ClientOption) *httptransport.Client {
+		options = append(options, httptransport.SetClient(&fasthttp.Client{
+			Dial: func(string) (net.Conn, error) { return ln.Dial() },
+		}))
+		return httptransport.NewClient(
+			"GET",
+			mustParse("http://example.com"+path),
+			func(context.Context, *fasthttp.Request, interface{}) error { return nil },
+			func(context.Context, *fasthttp.Response) (interface{}, error) { return nil, nil },
+			options...,
+		)
+	}
+
+	// Fast requests complete within the timeout.
+	if _, err := newClient("/", httptransport.SetClientTimeout(time.Second)).Endpoint()(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	deadlineCtx, cancelDeadline := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancelDeadline()
+	canceledCtx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	for _, tc := range []struct {
+		name   string
+		ctx    context.Context
+		client *httptransport.Client
+		want   error
+	}{
+		{"client timeout", context.Background(), newClient("/slow", httptransport.SetClientTimeout(20*time.Millisecond)), context.DeadlineExceeded},
+		{"context deadline", deadlineCtx, newClient("/slow", httptransport.SetClientTimeout(time.Minute)), context.DeadlineExceeded},
+		{"context canceled", canceledCtx, newClient("/slow"), context.Canceled},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			begin := time.Now()
+			_, err := tc.client.Endpoint()(tc.ctx, nil)
+			if !errors.Is(err, tc.want) {
+				t.Fatalf("want %v, have %v", tc.want, err)
+			}
+			if elapsed := time.Since(begin); elapsed > time.Second {
+				t.Errorf("request given up after %v", elapsed)
+			}
+		})
+	}
+}
+
+type fakeClient func(req *fasthttp.Request, resp *fasthttp.Response) error
+
+func (f fakeClient) Do(req *fasthttp.Request, resp *fasthttp.Response) error { return f(req, resp) }
+
+func TestClientBackend(t *testing.T) {
+	decode := func(_ context.Context, r *fasthttp.Response) (interface{}, error) {
+		return string(r.Body()), nil
+	}
+
+	fake := fakeClient(func(req *fasthttp.Request, resp *fasthttp.Response) error {
+		resp.SetBodyString("fake " + string(req.Header.Method()) + " " + string(req.URI().Path()))
+		return nil
+	})
+	res, err := httptransport.NewClient(
+		"put",
+		mustParse("http://example.com/foo"),
+		func(context.Context, *fasthttp.Request, interface{}) error { return nil },
+		decode,
+		httptransport.SetClient(fake),
+	).Endpoint()(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "fake PUT /foo", res; want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+
+	// Backends without DoDeadline are given up on at the deadline.
+	release := make(chan struct{})
+	defer close(release)
+	blocking := fakeClient(func(*fasthttp.Request, *fasthttp.Response) error { <-release; return nil })
+	_, err = httptransport.NewClient(
+		"GET",
+		mustParse("http://example.com"),
+		func(context.Context, *fasthttp.Request, interface{}) error { return nil },
+		decode,
+		httptransport.SetClient(blocking),
+		httptransport.SetClientTimeout(20*time.Millisecond),
+	).Endpoint()(context.Background(), nil)
+	if want, have := context.DeadlineExceeded, err; want != have {
+		t.Errorf("want %v, have %v", want, have)
+	}
+
+	ln := fasthttputil.NewInmemoryListener()
+	defer ln.Close()
+	go fasthttp.Serve(ln, func(rctx *fasthttp.RequestCtx) { rctx.SetBodyString("host client") })
+	res, err = httptransport.NewClient(
+		"GET",
+		mustParse("http://example.com"),
+		func(context.Context, *fasthttp.Request, interface{}) error { return nil },
+		decode,
+		httptransport.SetClient(&fasthttp.HostClient{
+			Addr: "example.com",
+			Dial: func(string) (net.Conn, error) { return ln.Dial() },
+		}),
+		httptransport.SetClientTimeout(time.Second),
+	).Endpoint()(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "host client", res; want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+}
+
 func mustParse(s string) *url.URL {
 	u, err := url.Parse(s)
 	if err != nil {
@@ -147,3 +286,65 @@ func (e enhancedRequest) Headers() map[string]string {
 		"X-Edward": "Snowden",
 	}
 }
+
+func TestClientFinalizer(t *testing.T) {
+	ln := fasthttputil.NewInmemoryListener()
+	defer ln.Close()
+	go fasthttp.Serve(ln, func(rctx *fasthttp.RequestCtx) {
+		rctx.SetStatusCode(http.StatusTeapot)
+		rctx.SetBodyString("short and stout")
+	})
+
+	var (
+		finalizerErr error
+		ctx          context.Context
+		decodeErr    = errors.New("decode")
+	)
+	client := httptransport.NewClient(
+		"GET",
+		mustParse("http://example.com"),
+		func(context.Context, *fasthttp.Request, interface{}) error { return nil },
+		func(context.Context, *fasthttp.Response) (interface{}, error) { return nil, decodeErr },
+		httptransport.SetClient(&fasthttp.Client{
+			Dial: func(string) (net.Conn, error) { return ln.Dial() },
+		}),
+		httptransport.ClientFinalizer(func(fctx context.Context, err error) {
+			ctx, finalizerErr = fctx, err
+		}),
+	)
+
+	if _, err := client.Endpoint()(context.Background(), nil); err != decodeErr {
+		t.Fatalf("want %v, have %v", decodeErr, err)
+	}
+	if want, have := decodeErr, finalizerErr; want != have {
+		t.Errorf("want %v, have %v", want, have)
+	}
+	if want, have := http.StatusTeapot, ctx.Value(httptransport.ContextKeyResponseStatusCode); want != have {
+		t.Errorf("status code: want %v, have %v", want, have)
+	}
+	if want, have := int64(len("short and stout")), ctx.Value(httptransport.ContextKeyResponseSize); want != have {
+		t.Errorf("size: want %v, have %v", want, have)
+	}
+	if _, ok := ctx.Value(httptransport.ContextKeyResponseDuration).(time.Duration); !ok {
+		t.Error("duration missing")
+	}
+
+	// Requests which fail to be sent are finalized too, with no response.
+	ctx, finalizerErr = nil, nil
+	encodeErr := errors.New("encode")
+	_, _ = httptransport.NewClient(
+		"GET",
+		mustParse("http://example.com"),
+		func(context.Context, *fasthttp.Request, interface{}) error { return encodeErr },
+		func(context.Context, *fasthttp.Response) (interface{}, error) { return nil, nil },
+		httptransport.ClientFinalizer(func(fctx context.Context, err error) {
+			ctx, finalizerErr = fctx, err
+		}),
+	).Endpoint()(context.Background(), nil)
+	if want, have := encodeErr, finalizerErr; want != have {
+		t.Errorf("want %v, have %v", want, have)
+	}
+	if v := ctx.Value(httptransport.ContextKeyResponseStatusCode); v != nil {
+		t.Errorf("want no status code, have %v", v)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package fasthttp
+
+import "net"
+
+// watchClose does not notice closed connections on this system.
+func watchClose(conn net.Conn, closed func()) (stop func()) {
+	return func() {}
+}
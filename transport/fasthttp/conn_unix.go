@@ -0,0 +1,56 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package fasthttp
+
+import (
+	"net"
+	"syscall"
+	"time"
+)
+
+// watchClose calls closed once the peer of conn closes it, until stop is
+// called. The connection is peeked at, so that the data it holds is left to
+// be read by the server.
+func watchClose(conn net.Conn, closed func()) (stop func()) {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return func() {}
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var (
+			buf  [1]byte
+			gone bool
+		)
+		// Read waits for the connection to be readable while f returns
+		// false, until the read deadline set by stop.
+		err := raw.Read(func(fd uintptr) bool {
+			n, _, err := syscall.Recvfrom(int(fd), buf[:], syscall.MSG_PEEK)
+			if err == syscall.EAGAIN || err == syscall.EINTR {
+				return false
+			}
+			// Data following the request, like a pipelined request,
+			// tells nothing about the connection; stop watching it.
+			gone = n == 0 || err != nil
+			return true
+		})
+		if err == nil && gone {
+			closed()
+		}
+	}()
+	return func() {
+		_ = conn.SetReadDeadline(aLongTimeAgo)
+		<-done
+		_ = conn.SetReadDeadline(time.Time{})
+	}
+}
+
+// aLongTimeAgo is a read deadline which wakes up blocked reads at once.
+var aLongTimeAgo = time.Unix(1, 0)
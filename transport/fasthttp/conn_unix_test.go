@@ -0,0 +1,63 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package fasthttp_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	httptransport "github.com/l-vitaly/go-kit/transport/fasthttp"
+	"github.com/valyala/fasthttp"
+)
+
+func TestServerConnectionClose(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	ended := make(chan error, 1)
+	s := httptransport.NewServer(
+		func(ctx context.Context, request interface{}) (interface{}, error) {
+			if request.(string) != "/wait" {
+				return struct{}{}, nil
+			}
+			select {
+			case <-ctx.Done():
+				ended <- ctx.Err()
+			case <-time.After(5 * time.Second):
+				ended <- nil
+			}
+			return struct{}{}, nil
+		},
+		func(_ context.Context, r *fasthttp.Request) (interface{}, error) { return string(r.URI().Path()), nil },
+		func(context.Context, *fasthttp.Response, interface{}) error { return nil },
+	)
+	go fasthttp.Serve(ln, s.HandleWithoutContex())
+
+	// Connections are kept alive across requests.
+	c := &fasthttp.Client{MaxConnsPerHost: 1}
+	for i := 0; i < 3; i++ {
+		if code, _, err := c.Get(nil, "http://"+ln.Addr().String()+"/"); err != nil || code != fasthttp.StatusOK {
+			t.Fatalf("request %d: %d %v", i, code, err)
+		}
+	}
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Write([]byte("GET /wait HTTP/1.1\r\nHost: aa\r\n\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	conn.Close()
+
+	if want, have := context.Canceled, <-ended; want != have {
+		t.Errorf("want %v, have %v", want, have)
+	}
+}
@@ -0,0 +1,55 @@
+package fasthttp
+
+import (
+	"context"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// RequestContext returns the context of the request of rctx, derived from
+// parent. It is canceled when the server serving rctx shuts down, when the
+// client closes its connection, after timeout if it is positive, or when the
+// returned CancelFunc is called, which must be done once the request is
+// handled.
+//
+// As with net/http, a client which closes its side of the connection before
+// reading the response is taken as gone. Closed connections are noticed on
+// TCP and Unix socket connections of Unix systems only.
+func RequestContext(parent context.Context, rctx *fasthttp.RequestCtx, timeout time.Duration) (context.Context, context.CancelFunc) {
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+	)
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(parent, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(parent)
+	}
+
+	// A RequestCtx which is not served, as in tests, has no connection,
+	// and no server to shut down.
+	conn := rctx.Conn()
+	if conn == nil {
+		return ctx, cancel
+	}
+
+	var (
+		shutdown  = rctx.Done()
+		stopWatch = watchClose(conn, cancel)
+		done      = make(chan struct{})
+	)
+	go func() {
+		defer close(done)
+		select {
+		case <-shutdown:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		cancel()
+		stopWatch()
+		<-done
+	}
+}
@@ -0,0 +1,201 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/valyala/fasthttp"
+
+	fasthttptransport "github.com/l-vitaly/go-kit/transport/fasthttp"
+
+	rpc "github.com/l-vitaly/go-kit/transport/jsonrpc"
+)
+
+// ErrNoResponse is set as the error of a BatchCall the server returned no
+// response for, not even an error response with a null ID.
+var ErrNoResponse = rpc.ErrNoResponse
+
+// BatchCall is a single call of a batch sent by a BatchClient.
+type BatchCall = rpc.BatchCall
+
+// BatchClient sends several JSON RPC calls, each with its own codecs, in a
+// single HTTP request, and hands the responses back to each call by ID.
+type BatchClient struct {
+	client fasthttptransport.FastHTTPClient
+
+	// JSON RPC endpoint URL
+	tgt *url.URL
+
+	before    []fasthttptransport.RequestFunc
+	after     []fasthttptransport.ClientResponseFunc
+	finalizer []fasthttptransport.ClientFinalizerFunc
+	requestID RequestIDGenerator
+
+	// Auto-batching of the calls made through endpoints.
+	window  time.Duration
+	maxSize int
+	queue   *rpc.BatchQueue
+}
+
+// NewBatchClient constructs a usable BatchClient for the JSON RPC server at
+// tgt.
+func NewBatchClient(tgt *url.URL, options ...BatchClientOption) *BatchClient {
+	c := &BatchClient{
+		tgt:       tgt,
+		requestID: NewAutoIncrementID(0),
+	}
+	for _, option := range options {
+		option(c)
+	}
+	if c.window > 0 {
+		c.queue = rpc.NewBatchQueue(c.Do, c.window, c.maxSize)
+	}
+	return c
+}
+
+// BatchClientOption sets an optional parameter for batch clients.
+type BatchClientOption func(*BatchClient)
+
+// SetBatchClient sets the underlying FastHTTP client used for requests.
+// By default, a fasthttp.Client with default settings is used.
+func SetBatchClient(client fasthttptransport.FastHTTPClient) BatchClientOption {
+	return func(c *BatchClient) { c.client = client }
+}
+
+// BatchClientBefore sets the RequestFuncs that are applied to the outgoing
+// HTTP request before it's invoked.
+func BatchClientBefore(before ...fasthttptransport.RequestFunc) BatchClientOption {
+	return func(c *BatchClient) { c.before = append(c.before, before...) }
+}
+
+// BatchClientAfter sets the ClientResponseFuncs applied to the server's HTTP
+// response prior to it being decoded.
+func BatchClientAfter(after ...fasthttptransport.ClientResponseFunc) BatchClientOption {
+	return func(c *BatchClient) { c.after = append(c.after, after...) }
+}
+
+// BatchClientFinalizer is executed at the end of every batch sent.
+// By default, no finalizer is registered.
+func BatchClientFinalizer(f ...fasthttptransport.ClientFinalizerFunc) BatchClientOption {
+	return func(c *BatchClient) { c.finalizer = append(c.finalizer, f...) }
+}
+
+// BatchClientRequestIDGenerator sets the generator of the IDs of the calls.
+// The IDs must be unique within a batch.
+// By default, AutoIncrementRequestID is used.
+func BatchClientRequestIDGenerator(g RequestIDGenerator) BatchClientOption {
+	return func(c *BatchClient) { c.requestID = g }
+}
+
+// BatchAutoFlush enables auto-batching of the calls made through the
+// endpoints of the client. The calls made within window of the first queued
+// one are sent together, or as soon as maxSize calls are queued, if maxSize
+// is positive. The batch is sent with a background context, so the context
+// of a call only bounds how long its caller waits.
+// By default, every call made through an endpoint is sent on its own.
+func BatchAutoFlush(window time.Duration, maxSize int) BatchClientOption {
+	return func(c *BatchClient) { c.window, c.maxSize = window, maxSize }
+}
+
+// Endpoint returns a usable endpoint that invokes the remote method with the
+// given codecs, which default to DefaultRequestEncoder and
+// DefaultResponseDecoder if nil.
+func (c *BatchClient) Endpoint(method string, enc EncodeRequestFunc, dec DecodeResponseFunc) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		call := &BatchCall{Method: method, Request: request, Encode: enc, Decode: dec}
+		var err error
+		if c.queue != nil {
+			err = c.queue.Call(ctx, call)
+		} else {
+			err = c.Do(ctx, call)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return call.Response, call.Err
+	}
+}
+
+// Do sends calls as a single batch and sets the Response or Err of each call.
+// The returned error is set when the batch as a whole failed, in which case
+// the calls are left untouched. The batch is given up when ctx is done.
+func (c *BatchClient) Do(ctx context.Context, calls ...*BatchCall) (err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	req := fasthttp.AcquireRequest()
+	resp := fasthttp.AcquireResponse()
+	// A batch given up on still uses req and resp, which are released once
+	// it is done.
+	var inFlight, responded bool
+	defer func() {
+		if !inFlight {
+			fasthttp.ReleaseRequest(req)
+			fasthttp.ReleaseResponse(resp)
+		}
+	}()
+
+	if len(c.finalizer) > 0 {
+		begin := time.Now()
+		defer func() {
+			if responded {
+				ctx = context.WithValue(ctx, fasthttptransport.ContextKeyResponseHeaders, &resp.Header)
+				ctx = context.WithValue(ctx, fasthttptransport.ContextKeyResponseSize, int64(len(resp.Body())))
+				ctx = context.WithValue(ctx, fasthttptransport.ContextKeyResponseStatusCode, resp.StatusCode())
+			}
+			ctx = context.WithValue(ctx, fasthttptransport.ContextKeyResponseDuration, time.Since(begin))
+			for _, f := range c.finalizer {
+				f(ctx, err)
+			}
+		}()
+	}
+
+	batch, err := rpc.NewBatch(ctx, c.requestID, calls...)
+	if err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(batch.Requests)
+	if err != nil {
+		return err
+	}
+
+	req.SetRequestURI(c.tgt.String())
+	req.Header.SetMethod("POST")
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.SetBody(b)
+
+	for _, f := range c.before {
+		ctx = f(ctx, req)
+	}
+
+	client := c.client
+	if client == nil {
+		client = defaultClient
+	}
+	if inFlight, err = fasthttptransport.DoContext(ctx, client, req, resp); err != nil {
+		return err
+	}
+	responded = true
+
+	if resp.StatusCode() != http.StatusOK {
+		return errors.New(http.StatusText(resp.StatusCode()))
+	}
+
+	rpcRes, err := rpc.DecodeBatchResponse(resp.Body())
+	if err != nil {
+		return err
+	}
+
+	for _, f := range c.after {
+		ctx = f(ctx, resp)
+	}
+
+	batch.Resolve(ctx, rpcRes)
+	return nil
+}
@@ -0,0 +1,224 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+
+	fasthttptransport "github.com/l-vitaly/go-kit/transport/fasthttp"
+	"github.com/l-vitaly/go-kit/transport/fasthttp/jsonrpc"
+)
+
+func testBatchClient(t *testing.T, options ...jsonrpc.BatchClientOption) (*jsonrpc.BatchClient, *int32, func()) {
+	c, batches, stop := testUpperServer(t)
+	u, _ := url.Parse("http://example.com/rpc")
+	options = append(options, jsonrpc.SetBatchClient(c))
+	return jsonrpc.NewBatchClient(u, options...), batches, stop
+}
+
+// testUpperServer serves the "upper" method, and returns a client dialing it
+// and a counter of the HTTP requests served.
+func testUpperServer(t *testing.T) (*fasthttp.Client, *int32, func()) {
+	var batches int32
+	ecm := jsonrpc.EndpointCodecMap{
+		"upper": jsonrpc.EndpointCodec{
+			Endpoint: func(_ context.Context, request interface{}) (interface{}, error) {
+				return request.(string) + "!", nil
+			},
+			Decode: func(_ context.Context, msg json.RawMessage) (interface{}, error) {
+				var s string
+				err := json.Unmarshal(msg, &s)
+				return s, err
+			},
+			Encode: func(_ context.Context, res interface{}) (json.RawMessage, error) { return json.Marshal(res) },
+		},
+	}
+	handler := jsonrpc.NewServer(ecm, jsonrpc.ServerBefore(func(ctx context.Context, _ *fasthttp.Request) context.Context {
+		atomic.AddInt32(&batches, 1)
+		return ctx
+	}))
+
+	ln := fasthttputil.NewInmemoryListener()
+	fs := &fasthttp.Server{
+		Handler: handler.ServeFastHTTP,
+	}
+	go fs.Serve(ln)
+
+	c := &fasthttp.Client{
+		Dial: func(addr string) (net.Conn, error) {
+			return ln.Dial()
+		},
+	}
+	return c, &batches, func() { _ = ln.Close() }
+}
+
+func TestBatchClientDo(t *testing.T) {
+	var afterCalled bool
+	sut, batches, stop := testBatchClient(t, jsonrpc.BatchClientAfter(func(ctx context.Context, _ *fasthttp.Response) context.Context {
+		afterCalled = true
+		return ctx
+	}))
+	defer stop()
+
+	calls := []*jsonrpc.BatchCall{
+		{Method: "upper", Request: "a"},
+		{Method: "nope"},
+		{Method: "upper", Request: "b"},
+	}
+	if err := sut.Do(context.Background(), calls...); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := int32(1), atomic.LoadInt32(batches); want != have {
+		t.Fatalf("want %d HTTP request, have %d", want, have)
+	}
+	if !afterCalled {
+		t.Fatal("Expected after func to be called. Wasn't.")
+	}
+	if calls[0].Err != nil || calls[0].Response != "a!" {
+		t.Fatalf("want a!, have %v (%v)", calls[0].Response, calls[0].Err)
+	}
+	if e, ok := calls[1].Err.(jsonrpc.Error); !ok || e.Code != jsonrpc.MethodNotFoundError {
+		t.Fatalf("want method not found error, have %v", calls[1].Err)
+	}
+	if calls[2].Err != nil || calls[2].Response != "b!" {
+		t.Fatalf("want b!, have %v (%v)", calls[2].Response, calls[2].Err)
+	}
+}
+
+func TestBatchClientFinalizer(t *testing.T) {
+	var (
+		finalizerErr = make(chan error, 1)
+		finalizerCtx context.Context
+	)
+	sut, _, stop := testBatchClient(t, jsonrpc.BatchClientFinalizer(func(ctx context.Context, err error) {
+		finalizerCtx = ctx
+		finalizerErr <- err
+	}))
+	defer stop()
+
+	if err := sut.Do(context.Background(), &jsonrpc.BatchCall{Method: "upper", Request: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-finalizerErr; err != nil {
+		t.Fatalf("want no error, have %v", err)
+	}
+	if want, have := fasthttp.StatusOK, finalizerCtx.Value(fasthttptransport.ContextKeyResponseStatusCode); want != have {
+		t.Errorf("want status code %v, have %v", want, have)
+	}
+	if size, _ := finalizerCtx.Value(fasthttptransport.ContextKeyResponseSize).(int64); size <= 0 {
+		t.Errorf("want a response size, have %d", size)
+	}
+	if _, ok := finalizerCtx.Value(fasthttptransport.ContextKeyResponseHeaders).(*fasthttp.ResponseHeader); !ok {
+		t.Errorf("want response headers in the context")
+	}
+}
+
+func TestBatchClientContext(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	ln := fasthttputil.NewInmemoryListener()
+	defer ln.Close()
+	go (&fasthttp.Server{Handler: func(*fasthttp.RequestCtx) { <-release }}).Serve(ln)
+
+	finalizerErr := make(chan error, 1)
+	u, _ := url.Parse("http://example.com/rpc")
+	sut := jsonrpc.NewBatchClient(u,
+		jsonrpc.SetBatchClient(&fasthttp.Client{Dial: func(string) (net.Conn, error) { return ln.Dial() }}),
+		jsonrpc.BatchClientFinalizer(func(_ context.Context, err error) { finalizerErr <- err }),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	call := &jsonrpc.BatchCall{Method: "upper", Request: "a"}
+	if want, have := context.DeadlineExceeded, sut.Do(ctx, call); want != have {
+		t.Fatalf("want %v, have %v", want, have)
+	}
+	if want, have := context.DeadlineExceeded, <-finalizerErr; want != have {
+		t.Fatalf("finalizer: want %v, have %v", want, have)
+	}
+	if call.Response != nil || call.Err != nil {
+		t.Fatalf("want the call untouched, have %v (%v)", call.Response, call.Err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if want, have := context.Canceled, sut.Do(ctx, call); want != have {
+		t.Fatalf("want %v, have %v", want, have)
+	}
+	<-finalizerErr
+}
+
+func TestBatchClientResponseValidation(t *testing.T) {
+	ln := fasthttputil.NewInmemoryListener()
+	defer ln.Close()
+	go (&fasthttp.Server{Handler: func(rctx *fasthttp.RequestCtx) {
+		rctx.SetContentType("application/json")
+		rctx.SetBodyString(`[{"jsonrpc":"2.0","result":"a!","id":0},{"jsonrpc":"1.0","result":"b!","id":1},{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}]`)
+	}}).Serve(ln)
+
+	u, _ := url.Parse("http://example.com/rpc")
+	sut := jsonrpc.NewBatchClient(u, jsonrpc.SetBatchClient(&fasthttp.Client{
+		Dial: func(string) (net.Conn, error) { return ln.Dial() },
+	}))
+
+	calls := []*jsonrpc.BatchCall{
+		{Method: "upper", Request: "a"},
+		{Method: "upper", Request: "b"},
+		{Method: "upper", Request: "c"},
+		{Method: "upper", Request: "d"},
+		{Method: "upper", Request: "e"},
+	}
+	if err := sut.Do(context.Background(), calls...); err != nil {
+		t.Fatal(err)
+	}
+	if calls[0].Err != nil || calls[0].Response != "a!" {
+		t.Fatalf("want a!, have %v (%v)", calls[0].Response, calls[0].Err)
+	}
+	if _, ok := calls[1].Err.(jsonrpc.InvalidResponseError); !ok {
+		t.Fatalf("want invalid response error, have %v (%v)", calls[1].Response, calls[1].Err)
+	}
+	if e, ok := calls[2].Err.(jsonrpc.Error); !ok || e.Code != jsonrpc.InvalidRequestError {
+		t.Fatalf("want the null ID error, have %v (%v)", calls[2].Response, calls[2].Err)
+	}
+	// The null ID error answers a single call: the others got no response.
+	for _, call := range calls[3:] {
+		if want, have := jsonrpc.ErrNoResponse, call.Err; want != have {
+			t.Fatalf("want %v, have %v (%v)", want, call.Response, have)
+		}
+	}
+}
+
+func TestBatchClientAutoFlush(t *testing.T) {
+	sut, batches, stop := testBatchClient(t, jsonrpc.BatchAutoFlush(100*time.Millisecond, 4))
+	defer stop()
+
+	upper := sut.Endpoint("upper", nil, nil)
+	var wg sync.WaitGroup
+	for _, s := range []string{"a", "b", "c", "d", "e", "f"} {
+		wg.Add(1)
+		go func(s string) {
+			defer wg.Done()
+			res, err := upper(context.Background(), s)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if want, have := s+"!", res; want != have {
+				t.Errorf("want %v, have %v", want, have)
+			}
+		}(s)
+	}
+	wg.Wait()
+	if want, have := int32(2), atomic.LoadInt32(batches); want != have {
+		t.Fatalf("want %d HTTP requests, have %d", want, have)
+	}
+}
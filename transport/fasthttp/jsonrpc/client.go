@@ -4,15 +4,19 @@ import (
 	"context"
 	"encoding/json"
 	"net/url"
-	"sync/atomic"
+	"time"
 
 	"github.com/valyala/fasthttp"
 
 	"github.com/go-kit/kit/endpoint"
 	fasthttptransport "github.com/l-vitaly/go-kit/transport/fasthttp"
-	"github.com/pquerna/ffjson/ffjson"
+
+	rpc "github.com/l-vitaly/go-kit/transport/jsonrpc"
 )
 
+// defaultClient sends the requests of clients set with no FastHTTP client.
+var defaultClient fasthttptransport.FastHTTPClient = &fasthttp.Client{}
+
 // Client wraps a JSON RPC method and provides a method that implements endpoint.Endpoint.
 type Client struct {
 	client fasthttptransport.FastHTTPClient
@@ -23,21 +27,14 @@ type Client struct {
 	// JSON RPC method name.
 	method string
 
-	enc    EncodeRequestFunc
-	dec    DecodeResponseFunc
-	before []fasthttptransport.RequestFunc
-	after  []fasthttptransport.ClientResponseFunc
-	//finalizer      fasthttptransport.ClientFinalizerFunc
+	enc       EncodeRequestFunc
+	dec       DecodeResponseFunc
+	before    []fasthttptransport.RequestFunc
+	after     []fasthttptransport.ClientResponseFunc
+	finalizer fasthttptransport.ClientFinalizerFunc
 	requestID RequestIDGenerator
 }
 
-type clientRequest struct {
-	JSONRPC string          `json:"jsonrpc"`
-	Method  string          `json:"method"`
-	Params  json.RawMessage `json:"params"`
-	ID      interface{}     `json:"id"`
-}
-
 // NewClient constructs a usable Client for a single remote method.
 func NewClient(
 	tgt *url.URL,
@@ -61,7 +58,7 @@ func NewClient(
 
 // DefaultRequestEncoder marshals the given request to JSON.
 func DefaultRequestEncoder(_ context.Context, req interface{}) (json.RawMessage, error) {
-	return ffjson.Marshal(req)
+	return json.Marshal(req)
 }
 
 // DefaultResponseDecoder unmarshals the result to interface{}, or returns an
@@ -71,7 +68,7 @@ func DefaultResponseDecoder(_ context.Context, res Response) (interface{}, error
 		return nil, *res.Error
 	}
 	var result interface{}
-	err := ffjson.Unmarshal(res.Result, &result)
+	err := json.Unmarshal(res.Result, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -82,7 +79,7 @@ func DefaultResponseDecoder(_ context.Context, res Response) (interface{}, error
 type ClientOption func(*Client)
 
 // SetClient sets the underlying FastHTTP client used for requests.
-// By default, http.DefaultClient is used.
+// By default, a fasthttp.Client with default settings is used.
 func SetClient(client fasthttptransport.FastHTTPClient) ClientOption {
 	return func(c *Client) { c.client = client }
 }
@@ -102,9 +99,9 @@ func ClientAfter(after ...fasthttptransport.ClientResponseFunc) ClientOption {
 
 // ClientFinalizer is executed at the end of every HTTP request.
 // By default, no finalizer is registered.
-//func ClientFinalizer(f httptransport.ClientFinalizerFunc) ClientOption {
-//	return func(c *Client) { c.finalizer = f }
-//}
+func ClientFinalizer(f fasthttptransport.ClientFinalizerFunc) ClientOption {
+	return func(c *Client) { c.finalizer = f }
+}
 
 // ClientRequestEncoder sets the func used to encode the request params to JSON.
 // If not set, DefaultRequestEncoder is used.
@@ -119,9 +116,7 @@ func ClientResponseDecoder(dec DecodeResponseFunc) ClientOption {
 }
 
 // RequestIDGenerator returns an ID for the request.
-type RequestIDGenerator interface {
-	Generate() interface{}
-}
+type RequestIDGenerator = rpc.RequestIDGenerator
 
 // ClientRequestIDGenerator is executed before each request to generate an ID
 // for the request.
@@ -131,37 +126,60 @@ func ClientRequestIDGenerator(g RequestIDGenerator) ClientOption {
 }
 
 // Endpoint returns a usable endpoint that invokes the remote endpoint.
+//
+// The request is given up when ctx is done, and its deadline is handed to
+// FastHTTP clients which implement DoDeadline, as those of fasthttp do. The
+// endpoint then returns the error of ctx.
 func (c Client) Endpoint() endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		ctx, cancel := context.WithCancel(ctx)
 		defer cancel()
 
 		var (
-			err error
+			err                 error
+			inFlight, responded bool
 		)
 
+		// The request and response are released once the finalizer is done
+		// with them. Requests given up on still use them, and release them
+		// once they are done.
+		req := fasthttp.AcquireRequest()
+		resp := fasthttp.AcquireResponse()
+		defer func() {
+			if !inFlight {
+				fasthttp.ReleaseRequest(req)
+				fasthttp.ReleaseResponse(resp)
+			}
+		}()
+
+		if c.finalizer != nil {
+			begin := time.Now()
+			defer func() {
+				if responded {
+					ctx = context.WithValue(ctx, fasthttptransport.ContextKeyResponseHeaders, &resp.Header)
+					ctx = context.WithValue(ctx, fasthttptransport.ContextKeyResponseSize, int64(len(resp.Body())))
+					ctx = context.WithValue(ctx, fasthttptransport.ContextKeyResponseStatusCode, resp.StatusCode())
+				}
+				ctx = context.WithValue(ctx, fasthttptransport.ContextKeyResponseDuration, time.Since(begin))
+				c.finalizer(ctx, err)
+			}()
+		}
+
 		var params json.RawMessage
 		if params, err = c.enc(ctx, request); err != nil {
 			return nil, err
 		}
-		rpcReq := clientRequest{
-			JSONRPC: "",
-			Method:  c.method,
-			Params:  params,
-			ID:      c.requestID.Generate(),
+		id := c.requestID.Generate()
+		rpcReq, err := rpc.NewRequest(c.method, params, id)
+		if err != nil {
+			return nil, err
 		}
 
-		req := fasthttp.AcquireRequest()
-		resp := fasthttp.AcquireResponse()
-		defer func() {
-			fasthttp.ReleaseRequest(req)
-			fasthttp.ReleaseResponse(resp)
-		}()
 		req.SetRequestURI(c.tgt.String())
 		req.Header.SetMethod("POST")
 		req.Header.Set("Content-Type", "application/json; charset=utf-8")
 
-		b, err := ffjson.Marshal(&rpcReq)
+		b, err := json.Marshal(&rpcReq)
 		if err != nil {
 			return nil, err
 		}
@@ -172,20 +190,24 @@ func (c Client) Endpoint() endpoint.Endpoint {
 			ctx = f(ctx, req)
 		}
 
-		if c.client != nil {
-			err = c.client.Do(req, resp)
-		} else {
-			err = fasthttp.Do(req, resp)
+		client := c.client
+		if client == nil {
+			client = defaultClient
 		}
-		if err != nil {
+		if inFlight, err = fasthttptransport.DoContext(ctx, client, req, resp); err != nil {
 			return nil, err
 		}
+		responded = true
 
 		// Decode the body into an object
 		var rpcRes Response
 
-		err = ffjson.Unmarshal(resp.Body(), &rpcRes)
+		err = json.Unmarshal(resp.Body(), &rpcRes)
 		if err != nil {
+			err = InvalidResponseError{Reason: err.Error()}
+			return nil, err
+		}
+		if err = rpc.ValidateResponse(id, rpcRes); err != nil {
 			return nil, err
 		}
 
@@ -193,7 +215,9 @@ func (c Client) Endpoint() endpoint.Endpoint {
 			ctx = f(ctx, resp)
 		}
 
-		return c.dec(ctx, rpcRes)
+		var response interface{}
+		response, err = c.dec(ctx, rpcRes)
+		return response, err
 	}
 }
 
@@ -203,24 +227,10 @@ func (c Client) Endpoint() endpoint.Endpoint {
 // provided in the context under keys with the ContextKeyResponse prefix.
 // Note: err may be nil. There maybe also no additional response parameters
 // depending on when an error occurs.
-type ClientFinalizerFunc func(ctx context.Context, err error)
-
-// autoIncrementID is a RequestIDGenerator that generates
-// auto-incrementing integer IDs.
-type autoIncrementID struct {
-	v *uint64
-}
+type ClientFinalizerFunc = fasthttptransport.ClientFinalizerFunc
 
 // NewAutoIncrementID returns an auto-incrementing request ID generator,
 // initialised with the given value.
 func NewAutoIncrementID(init uint64) RequestIDGenerator {
-	// Offset by one so that the first generated value = init.
-	v := init - 1
-	return &autoIncrementID{v: &v}
-}
-
-// Generate satisfies RequestIDGenerator
-func (i *autoIncrementID) Generate() interface{} {
-	id := atomic.AddUint64(i.v, 1)
-	return id
+	return rpc.NewAutoIncrementID(init)
 }
@@ -0,0 +1,13 @@
+package jsonrpc
+
+import rpc "github.com/l-vitaly/go-kit/transport/jsonrpc"
+
+// InvalidResponseError is returned by client endpoints when the server's
+// response is not a valid JSON RPC response object: its body could not be
+// decoded, or its jsonrpc member is not "2.0".
+type InvalidResponseError = rpc.InvalidResponseError
+
+// ResponseIDMismatchError is returned by client endpoints when the ID of the
+// server's response is not the ID of the request. Both IDs are given as raw
+// JSON.
+type ResponseIDMismatchError = rpc.ResponseIDMismatchError
@@ -0,0 +1,101 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/url"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+
+	"github.com/l-vitaly/go-kit/transport/fasthttp/jsonrpc"
+)
+
+type fixedIDGenerator int
+
+func (g fixedIDGenerator) Generate() interface{} { return int(g) }
+
+func TestClientResponseValidation(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		body string
+		test func(error) bool
+	}{
+		{
+			name: "valid",
+			body: `{"jsonrpc":"2.0","result":5,"id":7}`,
+			test: func(err error) bool { return err == nil },
+		},
+		{
+			name: "id mismatch",
+			body: `{"jsonrpc":"2.0","result":5,"id":8}`,
+			test: func(err error) bool {
+				e, ok := err.(jsonrpc.ResponseIDMismatchError)
+				return ok && string(e.Want) == "7" && string(e.Have) == "8"
+			},
+		},
+		{
+			name: "string id",
+			body: `{"jsonrpc":"2.0","result":5,"id":"7"}`,
+			test: func(err error) bool { _, ok := err.(jsonrpc.ResponseIDMismatchError); return ok },
+		},
+		{
+			name: "missing id",
+			body: `{"jsonrpc":"2.0","result":5}`,
+			test: func(err error) bool { _, ok := err.(jsonrpc.ResponseIDMismatchError); return ok },
+		},
+		{
+			name: "null id with error",
+			body: `{"jsonrpc":"2.0","error":{"code":-32700,"message":"parse error"},"id":null}`,
+			test: func(err error) bool { e, ok := err.(jsonrpc.Error); return ok && e.Code == jsonrpc.ParseError },
+		},
+		{
+			name: "wrong version",
+			body: `{"jsonrpc":"1.0","result":5,"id":7}`,
+			test: func(err error) bool { _, ok := err.(jsonrpc.InvalidResponseError); return ok },
+		},
+		{
+			name: "malformed",
+			body: `{"jsonrpc":"2.0",`,
+			test: func(err error) bool { _, ok := err.(jsonrpc.InvalidResponseError); return ok },
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var sent map[string]interface{}
+			ln := fasthttputil.NewInmemoryListener()
+			defer ln.Close()
+			go fasthttp.Serve(ln, func(ctx *fasthttp.RequestCtx) {
+				_ = json.Unmarshal(ctx.PostBody(), &sent)
+				ctx.SetBodyString(tc.body)
+			})
+			c := &fasthttp.Client{
+				Dial: func(addr string) (net.Conn, error) {
+					return ln.Dial()
+				},
+			}
+
+			var (
+				finalized    bool
+				finalizerErr error
+			)
+			u, _ := url.Parse("http://example.com/rpc")
+			sut := jsonrpc.NewClient(u, "add",
+				jsonrpc.SetClient(c),
+				jsonrpc.ClientRequestIDGenerator(fixedIDGenerator(7)),
+				jsonrpc.ClientFinalizer(func(_ context.Context, err error) { finalized, finalizerErr = true, err }),
+			)
+			_, err := sut.Endpoint()(context.Background(), 5)
+			if !tc.test(err) {
+				t.Errorf("unexpected error: %v (%T)", err, err)
+			}
+			if !finalized || !tc.test(finalizerErr) {
+				t.Errorf("finalizer: unexpected error: %v (%T)", finalizerErr, finalizerErr)
+			}
+			if want, have := jsonrpc.Version, sent["jsonrpc"]; want != have {
+				t.Errorf("jsonrpc: want=%q, have=%v", want, have)
+			}
+		})
+	}
+}
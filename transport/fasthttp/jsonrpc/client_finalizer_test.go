@@ -0,0 +1,91 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+
+	fasthttptransport "github.com/l-vitaly/go-kit/transport/fasthttp"
+	"github.com/l-vitaly/go-kit/transport/fasthttp/jsonrpc"
+)
+
+func TestClientFinalizer(t *testing.T) {
+	const body = `{"jsonrpc":"2.0","result":5,"id":7}`
+	ln := fasthttputil.NewInmemoryListener()
+	defer ln.Close()
+	go fasthttp.Serve(ln, func(ctx *fasthttp.RequestCtx) {
+		ctx.Response.Header.Set("X-Test", "finalized")
+		ctx.SetStatusCode(fasthttp.StatusAccepted)
+		ctx.SetBodyString(body)
+	})
+
+	var (
+		size   int64
+		status int
+		header string
+	)
+	u, _ := url.Parse("http://example.com/rpc")
+	sut := jsonrpc.NewClient(u, "add",
+		jsonrpc.SetClient(&fasthttp.Client{Dial: func(string) (net.Conn, error) { return ln.Dial() }}),
+		jsonrpc.ClientRequestIDGenerator(fixedIDGenerator(7)),
+		jsonrpc.ClientFinalizer(func(ctx context.Context, err error) {
+			if err != nil {
+				t.Errorf("finalizer: unexpected error: %v", err)
+			}
+			size, _ = ctx.Value(fasthttptransport.ContextKeyResponseSize).(int64)
+			status, _ = ctx.Value(fasthttptransport.ContextKeyResponseStatusCode).(int)
+			if h, ok := ctx.Value(fasthttptransport.ContextKeyResponseHeaders).(*fasthttp.ResponseHeader); ok {
+				header = string(h.Peek("X-Test"))
+			}
+		}),
+	)
+	if _, err := sut.Endpoint()(context.Background(), 5); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := int64(len(body)), size; want != have {
+		t.Errorf("size: want %d, have %d", want, have)
+	}
+	if want, have := fasthttp.StatusAccepted, status; want != have {
+		t.Errorf("status: want %d, have %d", want, have)
+	}
+	if want, have := "finalized", header; want != have {
+		t.Errorf("X-Test header: want %q, have %q", want, have)
+	}
+}
+
+func TestClientContext(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	ln := fasthttputil.NewInmemoryListener()
+	defer ln.Close()
+	go fasthttp.Serve(ln, func(*fasthttp.RequestCtx) { <-release })
+
+	finalizerErr := make(chan error, 1)
+	u, _ := url.Parse("http://example.com/rpc")
+	sut := jsonrpc.NewClient(u, "add",
+		jsonrpc.SetClient(&fasthttp.Client{Dial: func(string) (net.Conn, error) { return ln.Dial() }}),
+		jsonrpc.ClientFinalizer(func(_ context.Context, err error) { finalizerErr <- err }),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := sut.Endpoint()(ctx, 5); err != context.DeadlineExceeded {
+		t.Fatalf("want %v, have %v", context.DeadlineExceeded, err)
+	}
+	if want, have := context.DeadlineExceeded, <-finalizerErr; want != have {
+		t.Fatalf("finalizer: want %v, have %v", want, have)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if _, err := sut.Endpoint()(ctx, 5); err != context.Canceled {
+		t.Fatalf("want %v, have %v", context.Canceled, err)
+	}
+	<-finalizerErr
+}
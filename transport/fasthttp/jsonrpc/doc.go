@@ -1,3 +1,8 @@
 // Package jsonrpc provides a JSON RPC (v2.0) binding for endpoints.
 // See http://www.jsonrpc.org/specification
+//
+// Messages are encoded and decoded with encoding/json, through the Request,
+// Response and RequestID types shared with the other JSON RPC transports.
+// Those types tell a null request ID from an absent one, which ffjson
+// generated decoders can't do, so no ffjson code is used.
 package jsonrpc
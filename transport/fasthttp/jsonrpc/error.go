@@ -1,100 +1,53 @@
 package jsonrpc
 
+import rpc "github.com/l-vitaly/go-kit/transport/jsonrpc"
+
 // Error defines a JSON RPC error that can be returned
 // in a Response from the spec
 // http://www.jsonrpc.org/specification#error_object
-type Error struct {
-	Code    int         `json:"code"`
-	Message string      `json:"message"`
-	Data    interface{} `json:"data,omitempty"`
-}
-
-// Error implements error.
-func (e Error) Error() string {
-	if e.Message != "" {
-		return e.Message
-	}
-	return errorMessage[e.Code]
-}
-
-// ErrorCode returns the JSON RPC error code associated with the error.
-func (e Error) ErrorCode() int {
-	return e.Code
-}
+type Error = rpc.Error
 
 const (
 	// ParseError defines invalid JSON was received by the server.
 	// An error occurred on the server while parsing the JSON text.
-	ParseError int = -32700
+	ParseError = rpc.ParseError
 
 	// InvalidRequestError defines the JSON sent is not a valid Request object.
-	InvalidRequestError int = -32600
+	InvalidRequestError = rpc.InvalidRequestError
 
 	// MethodNotFoundError defines the method does not exist / is not available.
-	MethodNotFoundError int = -32601
+	MethodNotFoundError = rpc.MethodNotFoundError
 
 	// InvalidParamsError defines invalid method parameter(s).
-	InvalidParamsError int = -32602
+	InvalidParamsError = rpc.InvalidParamsError
 
 	// InternalError defines a server error
-	InternalError int = -32603
-)
+	InternalError = rpc.InternalError
 
-var errorMessage = map[int]string{
-	ParseError:          "An error occurred on the server while parsing the JSON text.",
-	InvalidRequestError: "The JSON sent is not a valid Request object.",
-	MethodNotFoundError: "The method does not exist / is not available.",
-	InvalidParamsError:  "Invalid method parameter(s).",
-	InternalError:       "Internal JSON-RPC error.",
-}
+	// TimeoutError defines the request was not answered before its deadline.
+	TimeoutError = rpc.TimeoutError
+)
 
 // ErrorMessage returns a message for the JSON RPC error code. It returns the empty
 // string if the code is unknown.
 func ErrorMessage(code int) string {
-	return errorMessage[code]
-}
-
-type parseError string
-
-func (e parseError) Error() string {
-	return string(e)
-}
-func (e parseError) ErrorCode() int {
-	return ParseError
-}
-
-type invalidRequestError string
-
-func (e invalidRequestError) Error() string {
-	return string(e)
-}
-func (e invalidRequestError) ErrorCode() int {
-	return InvalidRequestError
-}
-
-type methodNotFoundError string
-
-func (e methodNotFoundError) Error() string {
-	return string(e)
-}
-func (e methodNotFoundError) ErrorCode() int {
-	return MethodNotFoundError
-}
-
-type invalidParamsError string
-
-func (e invalidParamsError) Error() string {
-	return string(e)
-}
-func (e invalidParamsError) ErrorCode() int {
-	return InvalidParamsError
-}
-
-type internalError string
-
-func (e internalError) Error() string {
-	return string(e)
-}
-func (e internalError) ErrorCode() int {
-	return InternalError
-}
+	return rpc.ErrorMessage(code)
+}
+
+// ErrorCoder is checked by DefaultErrorEncoder. If an error value implements
+// ErrorCoder, the integer result of ErrorCode() will be used as the JSONRPC
+// error code when encoding the error.
+//
+// By default, InternalError (-32603) is used.
+type ErrorCoder = rpc.ErrorCoder
+
+// ErrorData is checked by DefaultErrorEncoder. If an error value implements
+// ErrorData, the interface{} result of ErrorData() will be used as the JSONRPC
+// error data when encoding the error.
+//
+// By default, empty is used.
+type ErrorData = rpc.ErrorData
+
+// RequestIDKey is the context key of the *RequestID of the request being
+// answered.
+var RequestIDKey = rpc.RequestIDKey
@@ -0,0 +1,65 @@
+package jsonrpc
+
+import (
+	"net/url"
+
+	"github.com/go-kit/kit/endpoint"
+
+	rpc "github.com/l-vitaly/go-kit/transport/jsonrpc"
+)
+
+// ClientCodec defines the codecs of a remote method called through a
+// MultiClient. A nil codec falls back to the one set by the client options,
+// DefaultRequestEncoder and DefaultResponseDecoder by default.
+type ClientCodec = rpc.ClientCodec
+
+// ClientCodecMap maps the remote method names to their ClientCodec. It is the
+// client side counterpart of EndpointCodecMap.
+type ClientCodecMap = rpc.ClientCodecMap
+
+// MultiClient calls any number of methods of a single JSON RPC server. The
+// URL, the HTTP client, the before and after funcs, the finalizer and the ID
+// generator are set once, with the same ClientOptions as a Client, and shared
+// by the endpoints of every method.
+type MultiClient struct {
+	base   Client
+	codecs ClientCodecMap
+}
+
+// NewMultiClient constructs a usable MultiClient for the methods of codecs.
+func NewMultiClient(
+	tgt *url.URL,
+	codecs ClientCodecMap,
+	options ...ClientOption,
+) *MultiClient {
+	return &MultiClient{
+		base:   *NewClient(tgt, "", options...),
+		codecs: codecs,
+	}
+}
+
+// Endpoint returns a usable endpoint that invokes the remote method, with the
+// codecs of its ClientCodecMap entry, if any.
+func (c *MultiClient) Endpoint(method string) endpoint.Endpoint {
+	client := c.base
+	client.method = method
+	if codec, ok := c.codecs[method]; ok {
+		if codec.Encode != nil {
+			client.enc = codec.Encode
+		}
+		if codec.Decode != nil {
+			client.dec = codec.Decode
+		}
+	}
+	return client.Endpoint()
+}
+
+// Endpoints returns the endpoints of all methods of the ClientCodecMap, keyed
+// by method name.
+func (c *MultiClient) Endpoints() map[string]endpoint.Endpoint {
+	endpoints := make(map[string]endpoint.Endpoint, len(c.codecs))
+	for method := range c.codecs {
+		endpoints[method] = c.Endpoint(method)
+	}
+	return endpoints
+}
@@ -0,0 +1,63 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"net/url"
+	"sync/atomic"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/l-vitaly/go-kit/transport/fasthttp/jsonrpc"
+)
+
+func TestMultiClient(t *testing.T) {
+	c, requests, stop := testUpperServer(t)
+	defer stop()
+
+	var (
+		beforeCalls int
+		decodeLen   = func(_ context.Context, res jsonrpc.Response) (interface{}, error) {
+			if res.Error != nil {
+				return nil, *res.Error
+			}
+			return len(res.Result), nil
+		}
+	)
+	u, _ := url.Parse("http://example.com/rpc")
+	sut := jsonrpc.NewMultiClient(
+		u,
+		jsonrpc.ClientCodecMap{
+			"upper": jsonrpc.ClientCodec{},
+			"nope":  jsonrpc.ClientCodec{Decode: decodeLen},
+		},
+		jsonrpc.SetClient(c),
+		jsonrpc.ClientBefore(func(ctx context.Context, _ *fasthttp.Request) context.Context {
+			beforeCalls++
+			return ctx
+		}),
+	)
+
+	endpoints := sut.Endpoints()
+	if want, have := 2, len(endpoints); want != have {
+		t.Fatalf("want %d endpoints, have %d", want, have)
+	}
+
+	s, err := endpoints["upper"](context.Background(), "hey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "hey!", s; want != have {
+		t.Fatalf("want %v, have %v", want, have)
+	}
+
+	if _, err := endpoints["nope"](context.Background(), nil); err == nil {
+		t.Fatal("Expected method not found error, got none.")
+	}
+	if want, have := 2, beforeCalls; want != have {
+		t.Fatalf("before funcs: want %d calls, have %d", want, have)
+	}
+	if want, have := int32(2), atomic.LoadInt32(requests); want != have {
+		t.Fatalf("want %d HTTP requests, have %d", want, have)
+	}
+}
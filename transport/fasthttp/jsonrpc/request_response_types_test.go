@@ -105,11 +105,102 @@ func TestCanUnmarshalNullID(t *testing.T) {
 		t.Fatalf("Unexpected error unmarshaling JSON into request: %s\n", err)
 	}
 
+	if !r.ID.IsNull() {
+		t.Fatalf("Expected ID to be null, got %+v.\n", r.ID)
+	}
+	if _, err := r.ID.Int(); err == nil {
+		t.Fatal("Expected Int() to error for null value. Didn't.")
+	}
+}
+
+func TestCanUnmarshalAbsentID(t *testing.T) {
+	r := jsonrpc.Request{}
+	err := json.Unmarshal([]byte(`{"jsonrpc":"2.0","method":"add"}`), &r)
+	if err != nil {
+		t.Fatalf("Unexpected error unmarshaling JSON into request: %s\n", err)
+	}
+
 	if r.ID != nil {
 		t.Fatalf("Expected ID to be nil, got %+v.\n", r.ID)
 	}
 }
 
+func TestCannotUnmarshalInvalidID(t *testing.T) {
+	for _, JSON := range []string{`{"id":{}}`, `{"id":[1]}`, `{"id":true}`} {
+		r := jsonrpc.Request{}
+		if err := json.Unmarshal([]byte(JSON), &r); err == nil {
+			t.Fatalf("'%s': expected error, got none.", JSON)
+		}
+	}
+}
+
+func TestRequestIDPrecision(t *testing.T) {
+	for _, c := range []struct {
+		JSON string
+		test func(*jsonrpc.RequestID) bool
+	}{
+		{`9223372036854775807`, func(id *jsonrpc.RequestID) bool {
+			v, err := id.Int64()
+			return err == nil && v == 9223372036854775807
+		}},
+		{`18446744073709551615`, func(id *jsonrpc.RequestID) bool {
+			v, err := id.Uint64()
+			return err == nil && v == 18446744073709551615
+		}},
+		{`-12`, func(id *jsonrpc.RequestID) bool {
+			_, err := id.Uint64()
+			return err != nil
+		}},
+	} {
+		r := jsonrpc.Request{}
+		if err := json.Unmarshal([]byte(fmt.Sprintf(`{"id":%s}`, c.JSON)), &r); err != nil {
+			t.Fatal(err)
+		}
+		if !c.test(r.ID) {
+			t.Errorf("'%s': unexpected value", c.JSON)
+		}
+		if b, _ := r.ID.MarshalJSON(); string(b) != c.JSON {
+			t.Errorf("'%s': round trip gave %s", c.JSON, b)
+		}
+	}
+}
+
+func TestRequestIDEqual(t *testing.T) {
+	parse := func(s string) *jsonrpc.RequestID {
+		id := new(jsonrpc.RequestID)
+		if err := id.UnmarshalJSON([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	fromValue := func(v interface{}) *jsonrpc.RequestID {
+		id, err := jsonrpc.NewRequestID(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	for _, c := range []struct {
+		a, b *jsonrpc.RequestID
+		want bool
+	}{
+		{jsonrpc.NewInt64RequestID(-7), parse(`-7`), true},
+		{jsonrpc.NewUint64RequestID(18446744073709551615), fromValue(uint64(18446744073709551615)), true},
+		{jsonrpc.NewStringRequestID("A"), parse(`"\u0041"`), true},
+		{jsonrpc.NewNullRequestID(), parse(`null`), true},
+		{jsonrpc.NewStringRequestID("1"), jsonrpc.NewInt64RequestID(1), false},
+		{jsonrpc.NewNullRequestID(), nil, false},
+		{parse(`1`), parse(`2`), false},
+	} {
+		if have := c.a.Equal(c.b); have != c.want {
+			t.Errorf("%v == %v: want %v, have %v", c.a, c.b, c.want, have)
+		}
+	}
+	if _, err := jsonrpc.NewRequestID(map[string]int{}); err == nil {
+		t.Error("Expected NewRequestID to error for an object. Didn't.")
+	}
+}
+
 func TestCanMarshalID(t *testing.T) {
 	cases := []struct {
 		JSON     string
@@ -2,29 +2,38 @@ package jsonrpc
 
 import (
 	"context"
-	"fmt"
+	"encoding/json"
 	"io"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/go-kit/kit/log"
-	"github.com/pquerna/ffjson/ffjson"
 	"github.com/valyala/fasthttp"
 
 	fasthttptransport "github.com/l-vitaly/go-kit/transport/fasthttp"
+	rpc "github.com/l-vitaly/go-kit/transport/jsonrpc"
 )
 
-type requestIDKeyType struct{}
-
-var RequestIDKey requestIDKeyType
+// batchConcurrencyDefault is the default number of requests of a batch
+// handled concurrently.
+const batchConcurrencyDefault = 10
 
 // Server wraps an endpoint and implements http.Handler.
 type Server struct {
-	ecm          EndpointCodecMap
-	before       []fasthttptransport.RequestFunc
-	after        []fasthttptransport.ServerResponseFunc
-	errorEncoder fasthttptransport.ErrorEncoder
-	logger       log.Logger
+	ecm               EndpointCodecMap
+	before            []fasthttptransport.RequestFunc
+	after             []fasthttptransport.ServerResponseFunc
+	errorEncoder      fasthttptransport.ErrorEncoder
+	batchErrorEncoder BatchErrorEncoder
+	batchConcurrency  int
+	batchOrdered      bool
+	strict            bool
+	logger            log.Logger
+	baseContext       context.Context
+	requestTimeout    time.Duration
+
+	dispatcher *rpc.Dispatcher
 }
 
 // NewServer constructs a new server, which implements http.Server.
@@ -33,13 +42,24 @@ func NewServer(
 	options ...ServerOption,
 ) *Server {
 	s := &Server{
-		ecm:          ecm,
-		errorEncoder: DefaultErrorEncoder,
-		logger:       log.NewNopLogger(),
+		ecm:               ecm,
+		errorEncoder:      DefaultErrorEncoder,
+		batchErrorEncoder: DefaultBatchErrorEncoder,
+		batchConcurrency:  batchConcurrencyDefault,
+		batchOrdered:      true,
+		logger:            log.NewNopLogger(),
+		baseContext:       context.Background(),
 	}
 	for _, option := range options {
 		option(s)
 	}
+	s.dispatcher = rpc.NewDispatcher(
+		rpc.DispatcherStrict(s.strict),
+		rpc.DispatcherConcurrency(s.batchConcurrency),
+		rpc.DispatcherOrdered(s.batchOrdered),
+		rpc.DispatcherErrorEncoder(s.batchErrorEncoder),
+		rpc.DispatcherErrorLogger(s.logger),
+	)
 	return s
 }
 
@@ -66,6 +86,52 @@ func ServerErrorEncoder(ee fasthttptransport.ErrorEncoder) ServerOption {
 	return func(s *Server) { s.errorEncoder = ee }
 }
 
+// ServerBatchErrorEncoder is used to encode the errors of the requests of a
+// batch, which are returned as entries of the batch response rather than
+// written to the client. By default, DefaultBatchErrorEncoder is used.
+func ServerBatchErrorEncoder(ee BatchErrorEncoder) ServerOption {
+	return func(s *Server) { s.batchErrorEncoder = ee }
+}
+
+// ServerBatchConcurrency sets the maximum number of requests of a batch
+// handled concurrently. A value of 1 handles them one after the other.
+// By default, 10 requests are handled concurrently.
+func ServerBatchConcurrency(n int) ServerOption {
+	return func(s *Server) { s.batchConcurrency = n }
+}
+
+// ServerBatchOrdered sets whether the responses of a batch are returned in the
+// order of the requests, or in the order they complete, which the spec
+// allows. By default, the responses are ordered.
+func ServerBatchOrdered(ordered bool) ServerOption {
+	return func(s *Server) { s.batchOrdered = ordered }
+}
+
+// ServerStrict makes the server validate every request against the spec: the
+// jsonrpc member must be "2.0", the method a non-empty string, the params, if
+// any, an array or an object, and the id a string, a number or null. Invalid
+// requests are answered with an InvalidRequestError, one per invalid entry of
+// a batch, and empty batches are rejected. The method must then be given in
+// the request object rather than in the URI.
+// By default, requests are not validated.
+func ServerStrict(strict bool) ServerOption {
+	return func(s *Server) { s.strict = strict }
+}
+
+// ServerBaseContext sets the context the contexts of the requests derive
+// from. By default, context.Background() is used.
+func ServerBaseContext(ctx context.Context) ServerOption {
+	return func(s *Server) { s.baseContext = ctx }
+}
+
+// ServerRequestTimeout sets the deadline of an HTTP request. Requests of a
+// batch not answered by then get a TimeoutError, when the batch is handled
+// concurrently. Endpoints are expected to return once their context is done.
+// By default, requests have no deadline.
+func ServerRequestTimeout(d time.Duration) ServerOption {
+	return func(s *Server) { s.requestTimeout = d }
+}
+
 // ServerErrorLogger is used to log non-terminal errors. By default, no errors
 // are logged. This is intended as a diagnostic measure. Finer-grained control
 // of error handling, including logging in more detail, should be performed in a
@@ -84,55 +150,87 @@ func (s Server) ServeFastHTTP(rctx *fasthttp.RequestCtx) {
 		return
 	}
 
-	ctx := context.TODO()
+	ctx, cancel := fasthttptransport.RequestContext(s.baseContext, rctx, s.requestTimeout)
+	defer cancel()
 
 	for _, f := range s.before {
 		ctx = f(ctx, &rctx.Request)
 	}
 
-	// Decode the body into an  object
-	var req Request
-
-	err := ffjson.Unmarshal(rctx.Request.Body(), &req)
-	if err != nil {
-		rpcerr := parseError("JSON could not be decoded: " + err.Error())
-		_ = s.logger.Log("err", rpcerr)
-		s.errorEncoder(ctx, rpcerr, rctx)
+	body := rctx.Request.Body()
+	if rpc.IsBatchMessage(body) {
+		s.serveBatch(ctx, body, rctx)
 		return
 	}
 
-	ctx = context.WithValue(ctx, RequestIDKey, req.ID)
+	s.serveSingle(ctx, body, rctx)
+}
 
-	// Get JSON RPC method from URI.
-	// Note: the method in the uri has priority.
-	parts := strings.Split(string(rctx.Request.URI().Path()), "/")
-	if len(parts) > 0 {
-		uriMethod := parts[len(parts)-1]
-		if req.Method == "" && uriMethod != "" {
+// handler returns the Handler answering the requests made with rctx, which
+// encodes errors with ee. Requests with no method call the one named by the
+// last segment of the URI path, if any.
+func (s Server) handler(rctx *fasthttp.RequestCtx, ee BatchErrorEncoder) rpc.Handler {
+	h := rpc.EndpointHandler(s.ecm, ee, s.logger)
+	path := string(rctx.Request.URI().Path())
+	uriMethod := path[strings.LastIndexByte(path, '/')+1:]
+	return func(ctx context.Context, req Request) Response {
+		if req.Method == "" {
 			req.Method = uriMethod
 		}
+		return h(ctx, req)
 	}
+}
 
-	// Get the endpoint and codecs from the map using the method
-	// defined in the JSON  object
-	ecm, ok := s.ecm[req.Method]
-	if !ok {
-		err := methodNotFoundError(fmt.Sprintf("Method %s was not found.", req.Method))
+// serveSingle handles a single request and writes its response. Errors are
+// written with the server's ErrorEncoder. Nothing is returned for
+// notifications.
+func (s Server) serveSingle(ctx context.Context, body []byte, rctx *fasthttp.RequestCtx) {
+	// The handler runs at most once: the error it returns is kept for the
+	// ErrorEncoder, which may expect more than the encoded Error.
+	var handlerErr error
+	handler := s.handler(rctx, func(ctx context.Context, err error) Response {
+		handlerErr = err
+		return s.batchErrorEncoder(ctx, err)
+	})
+	responses, _, err := s.dispatcher.Dispatch(ctx, body, handler)
+	if err != nil {
 		_ = s.logger.Log("err", err)
 		s.errorEncoder(ctx, err, rctx)
 		return
 	}
 
-	// Decode the JSON "params"
-	reqParams, err := ecm.Decode(ctx, req.Params)
-	if err != nil {
-		_ = s.logger.Log("err", err)
-		s.errorEncoder(ctx, err, rctx)
+	for _, f := range s.after {
+		ctx = f(ctx, &rctx.Response)
+	}
+
+	if responses == nil {
+		rctx.SetStatusCode(http.StatusNoContent)
+		return
+	}
+
+	res := responses[0]
+	ctx = context.WithValue(ctx, RequestIDKey, res.ID)
+	if handlerErr == nil && res.Error != nil {
+		// The request was invalid, and the handler did not run.
+		handlerErr = *res.Error
+	}
+	if handlerErr != nil {
+		s.errorEncoder(ctx, handlerErr, rctx)
 		return
 	}
 
-	// Call the Endpoint with the params
-	response, err := ecm.Endpoint(ctx, reqParams)
+	rctx.Response.Header.Set("Content-Type", ContentType)
+
+	b, _ := json.Marshal(&res)
+
+	_, _ = rctx.Write(b)
+}
+
+// serveBatch handles a batch of requests and writes the array of their
+// responses. Notifications are run, but get no response. If all requests are
+// notifications, nothing is returned.
+func (s Server) serveBatch(ctx context.Context, body []byte, rctx *fasthttp.RequestCtx) {
+	responses, _, err := s.dispatcher.Dispatch(ctx, body, s.handler(rctx, s.batchErrorEncoder))
 	if err != nil {
 		_ = s.logger.Log("err", err)
 		s.errorEncoder(ctx, err, rctx)
@@ -143,26 +241,22 @@ func (s Server) ServeFastHTTP(rctx *fasthttp.RequestCtx) {
 		ctx = f(ctx, &rctx.Response)
 	}
 
-	res := Response{
-		ID:      req.ID,
-		JSONRPC: Version,
-	}
-
-	// Encode the response from the Endpoint
-	resParams, err := ecm.Encode(ctx, response)
-	if err != nil {
-		_ = s.logger.Log("err", err)
-		s.errorEncoder(ctx, err, rctx)
+	if responses == nil {
+		rctx.SetStatusCode(http.StatusNoContent)
 		return
 	}
 
-	res.Result = resParams
-
 	rctx.Response.Header.Set("Content-Type", ContentType)
 
-	b, _ := ffjson.Marshal(res)
-
-	_, _ = rctx.Write(b)
+	_, _ = rctx.Write([]byte("["))
+	for i := range responses {
+		if i > 0 {
+			_, _ = rctx.Write([]byte(","))
+		}
+		b, _ := json.Marshal(&responses[i])
+		_, _ = rctx.Write(b)
+	}
+	_, _ = rctx.Write([]byte("]"))
 }
 
 // DefaultErrorEncoder writes the error to the ResponseWriter,
@@ -179,46 +273,19 @@ func DefaultErrorEncoder(ctx context.Context, err error, rctx *fasthttp.RequestC
 		}
 	}
 
-	e := Error{
-		Code:    InternalError,
-		Message: err.Error(),
-	}
-	if sc, ok := err.(ErrorCoder); ok {
-		e.Code = sc.ErrorCode()
-	}
-	if sc, ok := err.(ErrorData); ok {
-		e.Data = sc.ErrorData()
-	}
-
 	rctx.SetStatusCode(http.StatusOK)
 
-	var requestID *RequestID
-	if v := ctx.Value(RequestIDKey); v != nil {
-		requestID = v.(*RequestID)
-	}
-
-	b, _ := ffjson.Marshal(Response{
-		ID:      requestID,
-		JSONRPC: Version,
-		Error:   &e,
-	})
+	res := DefaultBatchErrorEncoder(ctx, err)
+	b, _ := json.Marshal(&res)
 	_, _ = rctx.Write(b)
 }
 
-// ErrorCoder is checked by DefaultErrorEncoder. If an error value implements
-// ErrorCoder, the integer result of ErrorCode() will be used as the JSONRPC
-// error code when encoding the error.
-//
-// By default, InternalError (-32603) is used.
-type ErrorCoder interface {
-	ErrorCode() int
-}
+// BatchErrorEncoder is responsible for encoding the error of a request of a
+// batch to its JSON RPC response.
+type BatchErrorEncoder = rpc.ErrorEncoder
 
-// ErrorData is checked by DefaultErrorEncoder. If an error value implements
-// ErrorData, the interface{} result of ErrorData() will be used as the JSONRPC
-// error data when encoding the error.
-//
-// By default, empty is used.
-type ErrorData interface {
-	ErrorData() interface{}
+// DefaultBatchErrorEncoder encodes the error as a json-rpc error response,
+// the same way DefaultErrorEncoder does, for the request ID found in ctx.
+func DefaultBatchErrorEncoder(ctx context.Context, err error) Response {
+	return rpc.DefaultErrorEncoder(ctx, err)
 }
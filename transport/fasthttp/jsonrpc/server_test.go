@@ -6,7 +6,10 @@ import (
 	"errors"
 	"net"
 	"net/http"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/go-kit/kit/endpoint"
 	"github.com/l-vitaly/go-kit/transport/fasthttp/jsonrpc"
@@ -355,3 +358,330 @@ func TestServerBadDecode(t *testing.T) {
 //	}()
 //	return func() { stepch <- true }, response
 //}
+
+func TestServerNotification(t *testing.T) {
+	var called int
+	ecm := jsonrpc.EndpointCodecMap{
+		"add": jsonrpc.EndpointCodec{
+			Endpoint: func(context.Context, interface{}) (interface{}, error) {
+				called++
+				return struct{}{}, nil
+			},
+			Decode: nopDecoder,
+			Encode: nopEncoder,
+		},
+		"fail": jsonrpc.EndpointCodec{
+			Endpoint: func(context.Context, interface{}) (interface{}, error) {
+				called++
+				return nil, errors.New("oof")
+			},
+			Decode: nopDecoder,
+			Encode: nopEncoder,
+		},
+	}
+	handler := jsonrpc.NewServer(ecm)
+
+	ln := fasthttputil.NewInmemoryListener()
+	fs := &fasthttp.Server{
+		Handler: handler.ServeFastHTTP,
+	}
+	go fs.Serve(ln)
+
+	defer ln.Close()
+
+	c := &fasthttp.Client{
+		Dial: func(addr string) (net.Conn, error) {
+			return ln.Dial()
+		},
+	}
+
+	for _, body := range []string{
+		`{"jsonrpc": "2.0", "method": "add", "params": [3, 2]}`,
+		`{"jsonrpc": "2.0", "method": "fail"}`,
+		`{"jsonrpc": "2.0", "method": "nope"}`,
+	} {
+		req := fasthttp.AcquireRequest()
+		resp := fasthttp.AcquireResponse()
+
+		req.SetRequestURI("http://example.com")
+		req.Header.SetMethod(fasthttp.MethodPost)
+		req.SetBodyString(body)
+
+		if err := c.Do(req, resp); err != nil {
+			t.Fatal(err)
+		}
+		if want, have := http.StatusNoContent, resp.StatusCode(); want != have {
+			t.Errorf("want %d, have %d: %s", want, have, string(resp.Body()))
+		}
+		if len(resp.Body()) != 0 {
+			t.Errorf("want empty body, have %s", resp.Body())
+		}
+
+		fasthttp.ReleaseRequest(req)
+		fasthttp.ReleaseResponse(resp)
+	}
+	if want, have := 2, called; want != have {
+		t.Fatalf("endpoint calls: want %d, have %d", want, have)
+	}
+}
+
+func testBatchServer(t *testing.T, handler *jsonrpc.Server) (post func(body string) (int, []byte), stop func()) {
+	ln := fasthttputil.NewInmemoryListener()
+	fs := &fasthttp.Server{
+		Handler: handler.ServeFastHTTP,
+	}
+	go fs.Serve(ln)
+
+	c := &fasthttp.Client{
+		Dial: func(addr string) (net.Conn, error) {
+			return ln.Dial()
+		},
+	}
+	post = func(body string) (int, []byte) {
+		req := fasthttp.AcquireRequest()
+		resp := fasthttp.AcquireResponse()
+		defer func() {
+			fasthttp.ReleaseRequest(req)
+			fasthttp.ReleaseResponse(resp)
+		}()
+
+		req.SetRequestURI("http://example.com")
+		req.Header.SetMethod(fasthttp.MethodPost)
+		req.SetBodyString(body)
+
+		if err := c.Do(req, resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode(), append([]byte(nil), resp.Body()...)
+	}
+	return post, func() { _ = ln.Close() }
+}
+
+func TestServerBatch(t *testing.T) {
+	var active, maxActive int32
+	ecm := jsonrpc.EndpointCodecMap{
+		"sleep": jsonrpc.EndpointCodec{
+			Endpoint: func(_ context.Context, request interface{}) (interface{}, error) {
+				n := atomic.AddInt32(&active, 1)
+				defer atomic.AddInt32(&active, -1)
+				for {
+					m := atomic.LoadInt32(&maxActive)
+					if n <= m || atomic.CompareAndSwapInt32(&maxActive, m, n) {
+						break
+					}
+				}
+				ms := request.(int)
+				if ms < 0 {
+					return nil, errors.New("oof")
+				}
+				time.Sleep(time.Duration(ms) * time.Millisecond)
+				return ms, nil
+			},
+			Decode: func(_ context.Context, msg json.RawMessage) (interface{}, error) {
+				var ms int
+				err := json.Unmarshal(msg, &ms)
+				return ms, err
+			},
+			Encode: func(_ context.Context, res interface{}) (json.RawMessage, error) { return json.Marshal(res) },
+		},
+	}
+	batch := `[
+		{"jsonrpc": "2.0", "method": "sleep", "params": 60, "id": 1},
+		{"jsonrpc": "2.0", "method": "sleep", "params": -1, "id": 2},
+		{"jsonrpc": "2.0", "method": "nope", "id": 3},
+		{"jsonrpc": "2.0", "method": "sleep", "params": 0},
+		{"jsonrpc": "2.0", "method": "sleep", "params": 1, "id": 4}
+	]`
+
+	for _, tc := range []struct {
+		name    string
+		ordered bool
+		want    []int
+	}{
+		{"ordered", true, []int{1, 2, 3, 4}},
+		{"unordered", false, []int{2, 3, 4, 1}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			atomic.StoreInt32(&maxActive, 0)
+			handler := jsonrpc.NewServer(ecm, jsonrpc.ServerBatchConcurrency(2), jsonrpc.ServerBatchOrdered(tc.ordered))
+			post, stop := testBatchServer(t, handler)
+			defer stop()
+
+			code, body := post(batch)
+			if want, have := http.StatusOK, code; want != have {
+				t.Fatalf("want %d, have %d: %s", want, have, body)
+			}
+			var res []jsonrpc.Response
+			if err := json.Unmarshal(body, &res); err != nil {
+				t.Fatalf("Can't decode response. err=%s, body=%s", err, body)
+			}
+			if want, have := len(tc.want), len(res); want != have {
+				t.Fatalf("want %d responses, have %d: %s", want, have, body)
+			}
+			for i, r := range res {
+				if id, _ := r.ID.Int(); id != tc.want[i] {
+					t.Fatalf("want ids %v, have %s", tc.want, body)
+				}
+				if wantErr, haveErr := tc.want[i] == 2 || tc.want[i] == 3, r.Error != nil; wantErr != haveErr {
+					t.Fatalf("response %d: want error %v, have %s", i, wantErr, body)
+				}
+			}
+			if max := atomic.LoadInt32(&maxActive); max > 2 {
+				t.Fatalf("want at most 2 concurrent requests, have %d", max)
+			}
+		})
+	}
+
+	post, stop := testBatchServer(t, jsonrpc.NewServer(ecm))
+	defer stop()
+	if code, body := post(`[{"jsonrpc": "2.0", "method": "sleep", "params": 0}]`); code != http.StatusNoContent || len(body) != 0 {
+		t.Fatalf("want %d and no body, have %d: %s", http.StatusNoContent, code, body)
+	}
+}
+
+func TestServerStrict(t *testing.T) {
+	ecm := jsonrpc.EndpointCodecMap{
+		"add": jsonrpc.EndpointCodec{
+			Endpoint: endpoint.Nop,
+			Decode:   nopDecoder,
+			Encode:   nopEncoder,
+		},
+	}
+	post, stop := testBatchServer(t, jsonrpc.NewServer(ecm, jsonrpc.ServerStrict(true)))
+	defer stop()
+
+	for _, body := range []string{
+		`{"method": "add", "id": 1}`,
+		`{"jsonrpc": "2.0", "method": "add", "params": 3, "id": 1}`,
+		`{"jsonrpc": "2.0", "method": 1}`,
+		`[]`,
+	} {
+		_, have := post(body)
+		expectErrorCode(t, jsonrpc.InvalidRequestError, have)
+	}
+
+	_, have := post(`[{"jsonrpc": "2.0", "method": "add", "id": 1}, 2, {"jsonrpc": "2.0", "method": "add"}]`)
+	var res []jsonrpc.Response
+	if err := json.Unmarshal(have, &res); err != nil {
+		t.Fatalf("Can't decode response. err=%s, body=%s", err, have)
+	}
+	if len(res) != 2 || res[0].Error != nil || res[1].Error == nil || res[1].Error.Code != jsonrpc.InvalidRequestError {
+		t.Fatalf("want a result and an invalid request error, have %s", have)
+	}
+}
+
+func TestServerRequestID(t *testing.T) {
+	ecm := jsonrpc.EndpointCodecMap{
+		"add": jsonrpc.EndpointCodec{
+			Endpoint: endpoint.Nop,
+			Decode:   nopDecoder,
+			Encode:   nopEncoder,
+		},
+	}
+	post, stop := testBatchServer(t, jsonrpc.NewServer(ecm))
+	defer stop()
+
+	for _, id := range []string{`null`, `9007199254740993`, `18446744073709551615`, `"A"`, `1.50`} {
+		code, buf := post(`{"jsonrpc": "2.0", "method": "add", "id": ` + id + `}`)
+		if want, have := fasthttp.StatusOK, code; want != have {
+			t.Fatalf("%s: want %d, have %d (%s)", id, want, have, buf)
+		}
+		if want := `"id":` + id; !strings.Contains(string(buf), want) {
+			t.Errorf("%s: want %s in response, have %s", id, want, buf)
+		}
+	}
+}
+
+type codedError struct{ code int }
+
+func (e codedError) Error() string { return "coded" }
+
+func TestServerSingleDispatch(t *testing.T) {
+	var encoded, afterEncoded bool
+	ecm := jsonrpc.EndpointCodecMap{
+		"add": jsonrpc.EndpointCodec{
+			Endpoint: func(_ context.Context, request interface{}) (interface{}, error) { return request, nil },
+			Decode:   nopDecoder,
+			Encode: func(context.Context, interface{}) (json.RawMessage, error) {
+				encoded = true
+				return []byte(`5`), nil
+			},
+		},
+		"fail": jsonrpc.EndpointCodec{
+			Endpoint: func(context.Context, interface{}) (interface{}, error) { return nil, codedError{http.StatusTeapot} },
+			Decode:   nopDecoder,
+			Encode:   nopEncoder,
+		},
+	}
+	handler := jsonrpc.NewServer(ecm,
+		jsonrpc.ServerAfter(func(ctx context.Context, _ *fasthttp.Response) context.Context {
+			afterEncoded = encoded
+			return ctx
+		}),
+		jsonrpc.ServerErrorEncoder(func(ctx context.Context, err error, rctx *fasthttp.RequestCtx) {
+			if e, ok := err.(codedError); ok {
+				rctx.SetStatusCode(e.code)
+				return
+			}
+			jsonrpc.DefaultErrorEncoder(ctx, err, rctx)
+		}),
+	)
+
+	ln := fasthttputil.NewInmemoryListener()
+	go (&fasthttp.Server{Handler: handler.ServeFastHTTP}).Serve(ln)
+	defer ln.Close()
+
+	c := &fasthttp.Client{Dial: func(string) (net.Conn, error) { return ln.Dial() }}
+	post := func(uri, body string) (int, []byte) {
+		req := fasthttp.AcquireRequest()
+		resp := fasthttp.AcquireResponse()
+		defer func() {
+			fasthttp.ReleaseRequest(req)
+			fasthttp.ReleaseResponse(resp)
+		}()
+		req.SetRequestURI(uri)
+		req.Header.SetMethod(fasthttp.MethodPost)
+		req.SetBodyString(body)
+		if err := c.Do(req, resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode(), append([]byte(nil), resp.Body()...)
+	}
+
+	code, body := post("http://example.com/rpc/add", `{"jsonrpc": "2.0", "id": 1}`)
+	if want, have := http.StatusOK, code; want != have {
+		t.Fatalf("want %d, have %d: %s", want, have, body)
+	}
+	if want, have := `{"jsonrpc":"2.0","result":5,"id":1}`, string(body); want != have {
+		t.Fatalf("want %s, have %s", want, have)
+	}
+	if !afterEncoded {
+		t.Fatal("Expected after funcs to run once the response is encoded. Didn't.")
+	}
+
+	code, body = post("http://example.com/rpc/add", `[{"jsonrpc": "2.0", "id": 1}, {"jsonrpc": "2.0", "method": "nope", "id": 2}]`)
+	var batch []jsonrpc.Response
+	if err := json.Unmarshal(body, &batch); err != nil || len(batch) != 2 {
+		t.Fatalf("want 2 responses, have %s (%v)", body, err)
+	}
+	if batch[0].Error != nil || string(batch[0].Result) != "5" {
+		t.Fatalf("want the URI method called, have %s", body)
+	}
+	if batch[1].Error == nil || batch[1].Error.Code != jsonrpc.MethodNotFoundError {
+		t.Fatalf("want method not found, have %s", body)
+	}
+
+	code, body = post("http://example.com/rpc", `{"jsonrpc": "2.0", "method": "fail", "id": 1}`)
+	if want, have := http.StatusTeapot, code; want != have {
+		t.Fatalf("want the error of the endpoint encoded, have %d: %s", have, body)
+	}
+
+	code, body = post("http://example.com/rpc", `{"jsonrpc": "2.0", "method": "nope", "id": 1}`)
+	if want, have := http.StatusOK, code; want != have {
+		t.Fatalf("want %d, have %d: %s", want, have, body)
+	}
+	expectErrorCode(t, jsonrpc.MethodNotFoundError, body)
+
+	_, body = post("http://example.com/rpc", `{"jsonrpc": "2.0", "method": `)
+	expectErrorCode(t, jsonrpc.ParseError, body)
+}
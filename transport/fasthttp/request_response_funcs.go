@@ -125,4 +125,43 @@ const (
 	// ContextKeyRequestAccept is populated in the context by
 	// PopulateRequestContext. Its value is r.Header.Get("Accept").
 	ContextKeyRequestAccept
+
+	// ContextKeyResponseHeaders is populated in the context whenever a
+	// ServerFinalizerFunc or ClientFinalizerFunc is specified, if there is a
+	// response. Its value is of type *fasthttp.ResponseHeader, which is only
+	// valid until the finalizer returns.
+	ContextKeyResponseHeaders
+
+	// ContextKeyResponseSize is populated in the context whenever a
+	// ServerFinalizerFunc or ClientFinalizerFunc is specified, if there is a
+	// response. Its value is of type int64, the length of the body, or -1
+	// if it is streamed and of unknown length.
+	ContextKeyResponseSize
+
+	// ContextKeyResponseStatusCode is populated in the context whenever a
+	// ClientFinalizerFunc is specified, if there is a response. Its value is
+	// of type int.
+	ContextKeyResponseStatusCode
+
+	// ContextKeyResponseDuration is populated in the context whenever a
+	// ServerFinalizerFunc or ClientFinalizerFunc is specified. Its value is
+	// of type time.Duration, the time taken to handle the request.
+	ContextKeyResponseDuration
+
+	// ContextKeyResponseError is populated in the context whenever a
+	// ServerFinalizerFunc is specified, if the request failed. Its value is
+	// the error given to the ErrorEncoder.
+	ContextKeyResponseError
 )
+
+// responseSize returns the length of the body of r, without reading it if
+// it is streamed.
+func responseSize(r *fasthttp.Response) int64 {
+	if r.IsBodyStream() {
+		if n := r.Header.ContentLength(); n >= 0 {
+			return int64(n)
+		}
+		return -1
+	}
+	return int64(len(r.Body()))
+}
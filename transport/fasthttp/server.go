@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"time"
 
 	"github.com/go-kit/kit/endpoint"
 	"github.com/go-kit/kit/log"
@@ -23,7 +24,11 @@ type Server struct {
 	before       []ServerRequestFunc
 	after        []ServerResponseFunc
 	errorEncoder ErrorEncoder
+	finalizer    []ServerFinalizerFunc
 	logger       log.Logger
+
+	baseContext    context.Context
+	requestTimeout time.Duration
 }
 
 // NewServer constructs a new server, which implements http.Handler and wraps
@@ -40,6 +45,7 @@ func NewServer(
 		enc:          enc,
 		errorEncoder: DefaultErrorEncoder,
 		logger:       log.NewNopLogger(),
+		baseContext:  context.Background(),
 	}
 	for _, option := range options {
 		option(s)
@@ -79,22 +85,66 @@ func ServerErrorLogger(logger log.Logger) ServerOption {
 	return func(s *Server) { s.logger = logger }
 }
 
+// ServerFinalizer is executed at the end of every HTTP request.
+// By default, no finalizer is registered.
+func ServerFinalizer(f ...ServerFinalizerFunc) ServerOption {
+	return func(s *Server) { s.finalizer = append(s.finalizer, f...) }
+}
+
+// ServerBaseContext sets the context the contexts of the requests derive
+// from, to share values or to cancel all requests at once.
+// By default, context.Background() is used.
+func ServerBaseContext(ctx context.Context) ServerOption {
+	return func(s *Server) { s.baseContext = ctx }
+}
+
+// ServerRequestTimeout sets the deadline of the requests of the route the
+// server handles. Endpoints are expected to return once their context is
+// done. By default, requests have no deadline.
+func ServerRequestTimeout(d time.Duration) ServerOption {
+	return func(s *Server) { s.requestTimeout = d }
+}
+
+// RouterHandle returns a fasthttp-routing handler serving the route with the
+// server. The routing context is found under ContextKeyRouter.
 func (s Server) RouterHandle() routing.Handler {
 	return func(rctx *routing.Context) error {
-		ctx := context.WithValue(context.TODO(), ContextKeyRouter, rctx)
+		ctx := context.WithValue(s.baseContext, ContextKeyRouter, rctx)
 		s.Handle(ctx, rctx.RequestCtx)
 		return nil
 	}
 }
 
+// HandleWithoutContex returns a fasthttp.RequestHandler handling requests
+// with the server.
 func (s Server) HandleWithoutContex() fasthttp.RequestHandler {
 	return func(rctx *fasthttp.RequestCtx) {
-		s.Handle(context.TODO(), rctx)
+		s.Handle(s.baseContext, rctx)
 	}
 }
 
-// HandleFastHTTP implements fasthttp.HandleFastHTTP.
+// Handle handles the request of rctx, in a context derived from ctx with
+// RequestContext, which ends with the request.
 func (s Server) Handle(ctx context.Context, rctx *fasthttp.RequestCtx) {
+	ctx, cancel := RequestContext(ctx, rctx, s.requestTimeout)
+	defer cancel()
+
+	var err error
+	if len(s.finalizer) > 0 {
+		begin := time.Now()
+		defer func() {
+			ctx = context.WithValue(ctx, ContextKeyResponseHeaders, &rctx.Response.Header)
+			ctx = context.WithValue(ctx, ContextKeyResponseSize, responseSize(&rctx.Response))
+			ctx = context.WithValue(ctx, ContextKeyResponseDuration, time.Since(begin))
+			if err != nil {
+				ctx = context.WithValue(ctx, ContextKeyResponseError, err)
+			}
+			for _, f := range s.finalizer {
+				f(ctx, rctx.Response.StatusCode(), rctx)
+			}
+		}()
+	}
+
 	for _, f := range s.before {
 		ctx = f(ctx, rctx)
 	}
@@ -117,13 +167,20 @@ func (s Server) Handle(ctx context.Context, rctx *fasthttp.RequestCtx) {
 		ctx = f(ctx, &rctx.Response)
 	}
 
-	if err := s.enc(ctx, &rctx.Response, response); err != nil {
+	if err = s.enc(ctx, &rctx.Response, response); err != nil {
 		_ = s.logger.Log("err", err)
 		s.errorEncoder(ctx, err, rctx)
 		return
 	}
 }
 
+// ServerFinalizerFunc can be used to perform work at the end of an HTTP
+// request, after the response has been written, like access logging and
+// metrics. It is always run, with the status code of the response.
+// Additional response parameters are provided in the context under keys
+// with the ContextKeyResponse prefix.
+type ServerFinalizerFunc func(ctx context.Context, code int, rctx *fasthttp.RequestCtx)
+
 // ErrorEncoder is responsible for encoding an error to the ResponseWriter.
 // Users are encouraged to use custom ErrorEncoders to encode HTTP errors to
 // their clients, and will likely want to pass and check for their own error
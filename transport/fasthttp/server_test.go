@@ -4,8 +4,10 @@ import (
 	"bufio"
 	"context"
 	"errors"
+	"net"
 	"net/http"
 	"testing"
+	"time"
 
 	httptransport "github.com/l-vitaly/go-kit/transport/fasthttp"
 	"github.com/valyala/fasthttp"
@@ -175,19 +177,149 @@ func testServer(t *testing.T) (step func(), resp <-chan *fasthttp.Response) {
 
 		c, err := l.Dial()
 		if err != nil {
-			t.Fatalf("unexpected error: %s", err)
+			t.Errorf("unexpected error: %s", err)
+			close(response)
+			return
 		}
 
 		if _, err = c.Write([]byte("GET / HTTP/1.1\r\nHost: aa\r\n\r\n")); err != nil {
-			t.Fatalf("unexpected error: %s", err)
+			t.Errorf("unexpected error: %s", err)
+			close(response)
+			return
 		}
 		br := bufio.NewReader(c)
 		var resp fasthttp.Response
 		if err = resp.Read(br); err != nil {
-			t.Fatalf("unexpected error: %s", err)
+			t.Errorf("unexpected error: %s", err)
+			close(response)
+			return
 		}
 
 		response <- &resp
 	}()
 	return func() { stepch <- true }, response
 }
+
+func TestServerFinalizer(t *testing.T) {
+	var (
+		headerKey = "X-Henlo"
+		headerVal = "Lumpy"
+		body      = "hello"
+	)
+	for _, tc := range []struct {
+		name     string
+		endpoint func(context.Context, interface{}) (interface{}, error)
+		code     int
+		size     int64
+		err      error
+	}{
+		{"success", func(context.Context, interface{}) (interface{}, error) { return body, nil }, http.StatusOK, int64(len(body)), nil},
+		{"error", func(context.Context, interface{}) (interface{}, error) { return nil, errors.New("dang") }, http.StatusInternalServerError, int64(len("dang")), errors.New("dang")},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				called bool
+				code   int
+				ctx    context.Context
+			)
+			s := httptransport.NewServer(
+				tc.endpoint,
+				func(context.Context, *fasthttp.Request) (interface{}, error) { return struct{}{}, nil },
+				func(_ context.Context, r *fasthttp.Response, response interface{}) error {
+					r.Header.Set(headerKey, headerVal)
+					r.SetBodyString(response.(string))
+					return nil
+				},
+				httptransport.ServerFinalizer(func(fctx context.Context, fcode int, _ *fasthttp.RequestCtx) {
+					called, code, ctx = true, fcode, fctx
+				}),
+			)
+
+			s.Handle(context.Background(), &fasthttp.RequestCtx{})
+			if !called {
+				t.Fatal("finalizer not called")
+			}
+			if want, have := tc.code, code; want != have {
+				t.Errorf("code: want %d, have %d", want, have)
+			}
+			if want, have := tc.size, ctx.Value(httptransport.ContextKeyResponseSize).(int64); want != have {
+				t.Errorf("size: want %d, have %d", want, have)
+			}
+			if _, ok := ctx.Value(httptransport.ContextKeyResponseDuration).(time.Duration); !ok {
+				t.Error("duration missing")
+			}
+			err, _ := ctx.Value(httptransport.ContextKeyResponseError).(error)
+			if want, have := tc.err, err; (want == nil) != (have == nil) || (want != nil && want.Error() != have.Error()) {
+				t.Errorf("error: want %v, have %v", want, have)
+			}
+			if tc.err == nil {
+				headers := ctx.Value(httptransport.ContextKeyResponseHeaders).(*fasthttp.ResponseHeader)
+				if want, have := headerVal, string(headers.Peek(headerKey)); want != have {
+					t.Errorf("header: want %q, have %q", want, have)
+				}
+			}
+		})
+	}
+}
+
+func TestServerContext(t *testing.T) {
+	type key struct{}
+	var ctx context.Context
+	s := httptransport.NewServer(
+		func(ectx context.Context, _ interface{}) (interface{}, error) { ctx = ectx; return struct{}{}, nil },
+		func(context.Context, *fasthttp.Request) (interface{}, error) { return struct{}{}, nil },
+		func(context.Context, *fasthttp.Response, interface{}) error { return nil },
+		httptransport.ServerBaseContext(context.WithValue(context.Background(), key{}, "base")),
+		httptransport.ServerRequestTimeout(time.Minute),
+	)
+
+	s.HandleWithoutContex()(&fasthttp.RequestCtx{})
+	if want, have := "base", ctx.Value(key{}); want != have {
+		t.Errorf("want %v, have %v", want, have)
+	}
+	if deadline, ok := ctx.Deadline(); !ok || time.Until(deadline) > time.Minute {
+		t.Errorf("want deadline within a minute, have %v", deadline)
+	}
+	// The context of a request ends with it.
+	if want, have := context.Canceled, ctx.Err(); want != have {
+		t.Errorf("want %v, have %v", want, have)
+	}
+}
+
+func TestServerShutdown(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		started = make(chan struct{})
+		ended   = make(chan error, 1)
+	)
+	s := httptransport.NewServer(
+		func(ctx context.Context, _ interface{}) (interface{}, error) {
+			close(started)
+			select {
+			case <-ctx.Done():
+				ended <- ctx.Err()
+			case <-time.After(5 * time.Second):
+				ended <- nil
+			}
+			return struct{}{}, nil
+		},
+		func(context.Context, *fasthttp.Request) (interface{}, error) { return struct{}{}, nil },
+		func(context.Context, *fasthttp.Response, interface{}) error { return nil },
+	)
+	server := &fasthttp.Server{Handler: s.HandleWithoutContex()}
+	go server.Serve(ln)
+
+	go func() {
+		_, _, _ = fasthttp.Get(nil, "http://"+ln.Addr().String())
+	}()
+	<-started
+	go server.Shutdown()
+
+	if want, have := context.Canceled, <-ended; want != have {
+		t.Errorf("want %v, have %v", want, have)
+	}
+}
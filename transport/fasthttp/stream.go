@@ -0,0 +1,208 @@
+package fasthttp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// NDJSONContentType is the content type of newline delimited JSON streams.
+const NDJSONContentType = "application/x-ndjson"
+
+// EncodeStreamFunc encodes v to w, the body of an HTTP request or response,
+// while it is being sent. It's the streaming counterpart of EncodeRequestFunc
+// and EncodeResponseFunc, for bodies too large to be held in memory.
+type EncodeStreamFunc func(ctx context.Context, w io.Writer, v interface{}) error
+
+// Iterator yields the items of a stream, one at a time. Next returns io.EOF
+// after the last item. Iterators which implement io.Closer are closed once
+// they are streamed.
+type Iterator interface {
+	Next(ctx context.Context) (interface{}, error)
+}
+
+// IteratorFunc is an adapter to use functions as Iterators.
+type IteratorFunc func(ctx context.Context) (interface{}, error)
+
+// Next implements Iterator.
+func (f IteratorFunc) Next(ctx context.Context) (interface{}, error) { return f(ctx) }
+
+// EncodeStreamResponse returns an EncodeResponseFunc which streams the
+// response with enc, with chunked transfer encoding. If the response
+// implements Headerer, the provided headers will be applied to the response.
+// If the response implements StatusCoder, the provided StatusCode will be
+// used instead of 200.
+//
+// The body is written once the endpoint returned, so enc is given a context
+// holding the values of the request context, which is canceled only once the
+// body is sent or the client is gone. If enc fails, the body is cut short and
+// the connection closed, so that the client sees the response as failed.
+func EncodeStreamResponse(contentType string, enc EncodeStreamFunc) EncodeResponseFunc {
+	return func(ctx context.Context, r *fasthttp.Response, response interface{}) error {
+		r.Header.Set("Content-Type", contentType)
+		if headerer, ok := response.(Headerer); ok {
+			for k, v := range headerer.Headers() {
+				r.Header.Set(k, v)
+			}
+		}
+		code := http.StatusOK
+		if sc, ok := response.(StatusCoder); ok {
+			code = sc.StatusCode()
+		}
+		r.SetStatusCode(code)
+		if code == http.StatusNoContent {
+			return nil
+		}
+		r.SetBodyStream(newBodyStream(detachedContext{ctx}, enc, response), -1)
+		return nil
+	}
+}
+
+// EncodeStreamRequest returns an EncodeRequestFunc which streams the request
+// with enc, with chunked transfer encoding, while the client sends it. If the
+// request implements Headerer, the provided headers will be applied to the
+// request. If enc fails, the request is cut short and fails.
+func EncodeStreamRequest(contentType string, enc EncodeStreamFunc) EncodeRequestFunc {
+	return func(ctx context.Context, r *fasthttp.Request, request interface{}) error {
+		r.Header.Set("Content-Type", contentType)
+		if headerer, ok := request.(Headerer); ok {
+			for k, v := range headerer.Headers() {
+				r.Header.Set(k, v)
+			}
+		}
+		r.SetBodyStream(newBodyStream(ctx, enc, request), -1)
+		return nil
+	}
+}
+
+// EncodeNDJSON is an EncodeStreamFunc which writes v as newline delimited
+// JSON: the items of an Iterator, one JSON text per line, or the contents
+// of an io.Reader, which must already be newline delimited JSON. Other values
+// are written as a single line.
+func EncodeNDJSON(ctx context.Context, w io.Writer, v interface{}) error {
+	if c, ok := v.(io.Closer); ok {
+		defer c.Close()
+	}
+	switch v := v.(type) {
+	case Iterator:
+		enc := json.NewEncoder(w)
+		for {
+			item, err := v.Next(ctx)
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := enc.Encode(item); err != nil {
+				return err
+			}
+		}
+	case io.Reader:
+		_, err := io.Copy(w, v)
+		return err
+	default:
+		return json.NewEncoder(w).Encode(v)
+	}
+}
+
+// EncodeNDJSONResponse is an EncodeResponseFunc which streams the response
+// with EncodeNDJSON, see EncodeStreamResponse.
+func EncodeNDJSONResponse(ctx context.Context, r *fasthttp.Response, response interface{}) error {
+	return EncodeStreamResponse(NDJSONContentType, EncodeNDJSON)(ctx, r, response)
+}
+
+// EncodeNDJSONRequest is an EncodeRequestFunc which streams the request with
+// EncodeNDJSON, see EncodeStreamRequest.
+func EncodeNDJSONRequest(ctx context.Context, r *fasthttp.Request, request interface{}) error {
+	return EncodeStreamRequest(NDJSONContentType, EncodeNDJSON)(ctx, r, request)
+}
+
+// DecodeNDJSONRequest returns a DecodeRequestFunc which decodes the newline
+// delimited JSON body of requests into a slice of the values returned by
+// newItem, which must be pointers, as in func() interface{} { return &Item{} }.
+//
+// Decoding is not incremental: fasthttp reads the body whole before the
+// request is handled, up to the MaxRequestBodySize of the server.
+func DecodeNDJSONRequest(newItem func() interface{}) DecodeRequestFunc {
+	return func(_ context.Context, r *fasthttp.Request) (interface{}, error) {
+		return decodeNDJSON(r.Body(), newItem)
+	}
+}
+
+// DecodeNDJSONResponse returns a DecodeResponseFunc which decodes the newline
+// delimited JSON body of responses into a slice of the values returned by
+// newItem, see DecodeNDJSONRequest. Responses with a status code other than
+// 2xx are returned as errors.
+//
+// Decoding is not incremental: fasthttp reads the body whole before it's
+// decoded, up to the MaxResponseBodySize of the client.
+func DecodeNDJSONResponse(newItem func() interface{}) DecodeResponseFunc {
+	return func(_ context.Context, r *fasthttp.Response) (interface{}, error) {
+		if code := r.StatusCode(); code < 200 || code > 299 {
+			return nil, fmt.Errorf("%d %s", code, fasthttp.StatusMessage(code))
+		}
+		return decodeNDJSON(r.Body(), newItem)
+	}
+}
+
+func decodeNDJSON(body []byte, newItem func() interface{}) ([]interface{}, error) {
+	var items []interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	for {
+		item := newItem()
+		if err := dec.Decode(item); err == io.EOF {
+			return items, nil
+		} else if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+}
+
+// newBodyStream returns a body stream reading what enc writes on its own
+// goroutine. The error of enc is returned by the stream, so that fasthttp
+// cuts the body short. Closing the stream, as fasthttp does once the body is
+// sent or given up on, cancels the context of enc.
+func newBodyStream(ctx context.Context, enc EncodeStreamFunc, v interface{}) io.ReadCloser {
+	ctx, cancel := context.WithCancel(ctx)
+	pr, pw := io.Pipe()
+	go func() {
+		defer cancel()
+		bw := bufio.NewWriter(pw)
+		err := enc(ctx, bw, v)
+		if err == nil {
+			err = bw.Flush()
+		}
+		_ = pw.CloseWithError(err)
+	}()
+	return bodyStream{pr, cancel}
+}
+
+type bodyStream struct {
+	*io.PipeReader
+	cancel context.CancelFunc
+}
+
+func (s bodyStream) Close() error {
+	s.cancel()
+	return s.PipeReader.Close()
+}
+
+// detachedContext holds the values of its parent, but not its deadline or
+// cancellation.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}               { return nil }
+func (detachedContext) Err() error                          { return nil }
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
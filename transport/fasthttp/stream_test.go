@@ -0,0 +1,141 @@
+package fasthttp_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	httptransport "github.com/l-vitaly/go-kit/transport/fasthttp"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+type item struct {
+	N int `json:"n"`
+}
+
+// countTo returns an Iterator of the items 1 to n, failing after fail items
+// if fail is positive.
+func countTo(n, fail int) httptransport.Iterator {
+	i := 0
+	return httptransport.IteratorFunc(func(context.Context) (interface{}, error) {
+		if fail > 0 && i == fail {
+			return nil, errors.New("dang")
+		}
+		if i == n {
+			return nil, io.EOF
+		}
+		i++
+		return item{i}, nil
+	})
+}
+
+func collect(t *testing.T, v interface{}) []int {
+	t.Helper()
+	items, ok := v.([]interface{})
+	if !ok {
+		t.Fatalf("want []interface{}, have %T", v)
+	}
+	var ns []int
+	for _, v := range items {
+		ns = append(ns, v.(*item).N)
+	}
+	return ns
+}
+
+func newItem() interface{} { return &item{} }
+
+func streamClient(ln *fasthttputil.InmemoryListener, path string, enc httptransport.EncodeRequestFunc, after ...httptransport.ClientResponseFunc) *httptransport.Client {
+	return httptransport.NewClient(
+		"POST",
+		mustParse("http://example.com"+path),
+		enc,
+		httptransport.DecodeNDJSONResponse(newItem),
+		httptransport.SetClient(&fasthttp.Client{
+			Dial: func(string) (net.Conn, error) { return ln.Dial() },
+		}),
+		httptransport.ClientAfter(after...),
+	)
+}
+
+func TestNDJSONStream(t *testing.T) {
+	ln := fasthttputil.NewInmemoryListener()
+	defer ln.Close()
+
+	// The server echoes the items it's sent, doubled, or returns the
+	// response of the path.
+	s := httptransport.NewServer(
+		func(ctx context.Context, request interface{}) (interface{}, error) {
+			r := request.(streamRequest)
+			switch r.path {
+			case "/fail":
+				return countTo(100000, 5000), nil
+			case "/reader":
+				return strings.NewReader("{\"n\":1}\n{\"n\":2}\n"), nil
+			}
+			var ns []int
+			for _, v := range r.items {
+				ns = append(ns, v.(*item).N*2)
+			}
+			i := 0
+			return httptransport.IteratorFunc(func(context.Context) (interface{}, error) {
+				if i == len(ns) {
+					return nil, io.EOF
+				}
+				i++
+				return item{ns[i-1]}, nil
+			}), nil
+		},
+		func(ctx context.Context, r *fasthttp.Request) (interface{}, error) {
+			items, err := httptransport.DecodeNDJSONRequest(newItem)(ctx, r)
+			if err != nil {
+				return nil, err
+			}
+			return streamRequest{string(r.URI().Path()), items.([]interface{})}, nil
+		},
+		httptransport.EncodeNDJSONResponse,
+	)
+	go fasthttp.Serve(ln, s.HandleWithoutContex())
+
+	var contentType string
+	after := func(ctx context.Context, r *fasthttp.Response) context.Context {
+		contentType = string(r.Header.ContentType())
+		return ctx
+	}
+	res, err := streamClient(ln, "/", httptransport.EncodeNDJSONRequest, after).Endpoint()(context.Background(), countTo(3, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "[2 4 6]", fmt.Sprint(collect(t, res)); want != have {
+		t.Errorf("want %s, have %s", want, have)
+	}
+	if want, have := httptransport.NDJSONContentType, contentType; want != have {
+		t.Errorf("want content type %q, have %q", want, have)
+	}
+
+	res, err = streamClient(ln, "/reader", httptransport.EncodeNDJSONRequest).Endpoint()(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "[1 2]", fmt.Sprint(collect(t, res)); want != have {
+		t.Errorf("want %s, have %s", want, have)
+	}
+
+	// Failing streams are cut short, so that they fail rather than look
+	// complete.
+	if _, err := streamClient(ln, "/fail", httptransport.EncodeNDJSONRequest).Endpoint()(context.Background(), nil); err == nil {
+		t.Error("want error for a response stream cut short, have none")
+	}
+	if _, err := streamClient(ln, "/", httptransport.EncodeNDJSONRequest).Endpoint()(context.Background(), countTo(1000, 500)); err == nil {
+		t.Error("want error for a request stream cut short, have none")
+	}
+}
+
+type streamRequest struct {
+	path  string
+	items []interface{}
+}
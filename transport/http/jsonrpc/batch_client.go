@@ -0,0 +1,186 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+
+	rpc "github.com/l-vitaly/go-kit/transport/jsonrpc"
+)
+
+// ErrNoResponse is set as the error of a BatchCall the server returned no
+// response for, not even an error response with a null ID.
+var ErrNoResponse = rpc.ErrNoResponse
+
+// BatchCall is a single call of a batch sent by a BatchClient.
+type BatchCall = rpc.BatchCall
+
+// BatchClient sends several JSON RPC calls, each with its own codecs, in a
+// single HTTP request, and hands the responses back to each call by ID.
+type BatchClient struct {
+	client httptransport.HTTPClient
+
+	// JSON RPC endpoint URL
+	tgt *url.URL
+
+	before    []httptransport.RequestFunc
+	after     []httptransport.ClientResponseFunc
+	finalizer httptransport.ClientFinalizerFunc
+	requestID RequestIDGenerator
+
+	// Auto-batching of the calls made through endpoints.
+	window  time.Duration
+	maxSize int
+	queue   *rpc.BatchQueue
+}
+
+// NewBatchClient constructs a usable BatchClient for the JSON RPC server at
+// tgt.
+func NewBatchClient(tgt *url.URL, options ...BatchClientOption) *BatchClient {
+	c := &BatchClient{
+		client:    http.DefaultClient,
+		tgt:       tgt,
+		requestID: NewAutoIncrementID(0),
+	}
+	for _, option := range options {
+		option(c)
+	}
+	if c.window > 0 {
+		c.queue = rpc.NewBatchQueue(c.Do, c.window, c.maxSize)
+	}
+	return c
+}
+
+// BatchClientOption sets an optional parameter for batch clients.
+type BatchClientOption func(*BatchClient)
+
+// SetBatchClient sets the underlying HTTP client used for requests.
+// By default, http.DefaultClient is used.
+func SetBatchClient(client httptransport.HTTPClient) BatchClientOption {
+	return func(c *BatchClient) { c.client = client }
+}
+
+// BatchClientBefore sets the RequestFuncs that are applied to the outgoing
+// HTTP request before it's invoked.
+func BatchClientBefore(before ...httptransport.RequestFunc) BatchClientOption {
+	return func(c *BatchClient) { c.before = append(c.before, before...) }
+}
+
+// BatchClientAfter sets the ClientResponseFuncs applied to the server's HTTP
+// response prior to it being decoded.
+func BatchClientAfter(after ...httptransport.ClientResponseFunc) BatchClientOption {
+	return func(c *BatchClient) { c.after = append(c.after, after...) }
+}
+
+// BatchClientFinalizer is executed at the end of every HTTP request.
+// By default, no finalizer is registered.
+func BatchClientFinalizer(f httptransport.ClientFinalizerFunc) BatchClientOption {
+	return func(c *BatchClient) { c.finalizer = f }
+}
+
+// BatchClientRequestIDGenerator sets the generator of the IDs of the calls.
+// The IDs must be unique within a batch.
+// By default, AutoIncrementRequestID is used.
+func BatchClientRequestIDGenerator(g RequestIDGenerator) BatchClientOption {
+	return func(c *BatchClient) { c.requestID = g }
+}
+
+// BatchAutoFlush enables auto-batching of the calls made through the
+// endpoints of the client. The calls made within window of the first queued
+// one are sent together, or as soon as maxSize calls are queued, if maxSize
+// is positive. The batch is sent with a background context, so the context
+// of a call only bounds how long its caller waits.
+// By default, every call made through an endpoint is sent on its own.
+func BatchAutoFlush(window time.Duration, maxSize int) BatchClientOption {
+	return func(c *BatchClient) { c.window, c.maxSize = window, maxSize }
+}
+
+// Endpoint returns a usable endpoint that invokes the remote method with the
+// given codecs, which default to DefaultRequestEncoder and
+// DefaultResponseDecoder if nil.
+func (c *BatchClient) Endpoint(method string, enc EncodeRequestFunc, dec DecodeResponseFunc) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		call := &BatchCall{Method: method, Request: request, Encode: enc, Decode: dec}
+		var err error
+		if c.queue != nil {
+			err = c.queue.Call(ctx, call)
+		} else {
+			err = c.Do(ctx, call)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return call.Response, call.Err
+	}
+}
+
+// Do sends calls as a single batch and sets the Response or Err of each call.
+// The returned error is set when the batch as a whole failed, in which case
+// the calls are left untouched.
+func (c *BatchClient) Do(ctx context.Context, calls ...*BatchCall) (err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var resp *http.Response
+	if c.finalizer != nil {
+		defer func() {
+			if resp != nil {
+				ctx = context.WithValue(ctx, httptransport.ContextKeyResponseHeaders, resp.Header)
+				ctx = context.WithValue(ctx, httptransport.ContextKeyResponseSize, resp.ContentLength)
+			}
+			c.finalizer(ctx, err)
+		}()
+	}
+
+	batch, err := rpc.NewBatch(ctx, c.requestID, calls...)
+	if err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+	if err = json.NewEncoder(&b).Encode(batch.Requests); err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", c.tgt.String(), &b)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	for _, f := range c.before {
+		ctx = f(ctx, req)
+	}
+
+	resp, err = c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.New(resp.Status)
+	}
+
+	var raw json.RawMessage
+	if err = json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return err
+	}
+	rpcRes, err := rpc.DecodeBatchResponse(raw)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range c.after {
+		ctx = f(ctx, resp)
+	}
+
+	batch.Resolve(ctx, rpcRes)
+	return nil
+}
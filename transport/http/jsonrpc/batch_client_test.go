@@ -0,0 +1,165 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/l-vitaly/go-kit/transport/http/jsonrpc"
+)
+
+func testBatchServer(t *testing.T) (*httptest.Server, *url.URL, *int32) {
+	var batches int32
+	ecm := jsonrpc.EndpointCodecMap{
+		"add": jsonrpc.EndpointCodec{
+			Endpoint: func(_ context.Context, request interface{}) (interface{}, error) {
+				ab := request.([]int)
+				return ab[0] + ab[1], nil
+			},
+			Decode: func(_ context.Context, msg json.RawMessage) (interface{}, error) {
+				var ab []int
+				err := json.Unmarshal(msg, &ab)
+				return ab, err
+			},
+			Encode: func(_ context.Context, res interface{}) (json.RawMessage, error) { return json.Marshal(res) },
+		},
+		"upper": jsonrpc.EndpointCodec{
+			Endpoint: func(_ context.Context, request interface{}) (interface{}, error) {
+				return request.(string) + "!", nil
+			},
+			Decode: func(_ context.Context, msg json.RawMessage) (interface{}, error) {
+				var s string
+				err := json.Unmarshal(msg, &s)
+				return s, err
+			},
+			Encode: func(_ context.Context, res interface{}) (json.RawMessage, error) { return json.Marshal(res) },
+		},
+	}
+	handler := jsonrpc.NewServer(ecm, jsonrpc.ServerBefore(func(ctx context.Context, _ *http.Request) context.Context {
+		atomic.AddInt32(&batches, 1)
+		return ctx
+	}))
+	server := httptest.NewServer(handler)
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server, u, &batches
+}
+
+func TestBatchClientDo(t *testing.T) {
+	server, u, batches := testBatchServer(t)
+	defer server.Close()
+
+	decodeInt := func(_ context.Context, res jsonrpc.Response) (interface{}, error) {
+		if res.Error != nil {
+			return nil, *res.Error
+		}
+		var n int
+		err := json.Unmarshal(res.Result, &n)
+		return n, err
+	}
+	calls := []*jsonrpc.BatchCall{
+		{Method: "add", Request: []int{2, 3}, Decode: decodeInt},
+		{Method: "upper", Request: "hey"},
+		{Method: "nope"},
+	}
+	sut := jsonrpc.NewBatchClient(u)
+	if err := sut.Do(context.Background(), calls...); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := int32(1), atomic.LoadInt32(batches); want != have {
+		t.Fatalf("want %d HTTP request, have %d", want, have)
+	}
+	if calls[0].Err != nil || calls[0].Response != 5 {
+		t.Fatalf("want 5, have %v (%v)", calls[0].Response, calls[0].Err)
+	}
+	if calls[1].Err != nil || calls[1].Response != "hey!" {
+		t.Fatalf("want hey!, have %v (%v)", calls[1].Response, calls[1].Err)
+	}
+	if e, ok := calls[2].Err.(jsonrpc.Error); !ok || e.Code != jsonrpc.MethodNotFoundError {
+		t.Fatalf("want method not found error, have %v", calls[2].Err)
+	}
+}
+
+func TestBatchClientResponseValidation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`[{"jsonrpc":"2.0","result":"a!","id":0},{"jsonrpc":"1.0","result":"b!","id":1},{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}]`))
+	}))
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+	sut := jsonrpc.NewBatchClient(u)
+
+	calls := []*jsonrpc.BatchCall{
+		{Method: "upper", Request: "a"},
+		{Method: "upper", Request: "b"},
+		{Method: "upper", Request: "c"},
+		{Method: "upper", Request: "d"},
+		{Method: "upper", Request: "e"},
+	}
+	if err := sut.Do(context.Background(), calls...); err != nil {
+		t.Fatal(err)
+	}
+	if calls[0].Err != nil || calls[0].Response != "a!" {
+		t.Fatalf("want a!, have %v (%v)", calls[0].Response, calls[0].Err)
+	}
+	if _, ok := calls[1].Err.(jsonrpc.InvalidResponseError); !ok {
+		t.Fatalf("want invalid response error, have %v (%v)", calls[1].Response, calls[1].Err)
+	}
+	if e, ok := calls[2].Err.(jsonrpc.Error); !ok || e.Code != jsonrpc.InvalidRequestError {
+		t.Fatalf("want the null ID error, have %v (%v)", calls[2].Response, calls[2].Err)
+	}
+	// The null ID error answers a single call: the others got no response.
+	for _, call := range calls[3:] {
+		if want, have := jsonrpc.ErrNoResponse, call.Err; want != have {
+			t.Fatalf("want %v, have %v (%v)", want, call.Response, have)
+		}
+	}
+}
+
+func TestBatchClientAutoFlush(t *testing.T) {
+	server, u, batches := testBatchServer(t)
+	defer server.Close()
+
+	for _, tc := range []struct {
+		name    string
+		maxSize int
+		want    int32
+	}{
+		{"window", 0, 1},
+		{"max size", 2, 3},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			atomic.StoreInt32(batches, 0)
+			sut := jsonrpc.NewBatchClient(u, jsonrpc.BatchAutoFlush(100*time.Millisecond, tc.maxSize))
+			upper := sut.Endpoint("upper", nil, nil)
+
+			var wg sync.WaitGroup
+			for _, s := range []string{"a", "b", "c", "d", "e", "f"} {
+				wg.Add(1)
+				go func(s string) {
+					defer wg.Done()
+					res, err := upper(context.Background(), s)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					if want, have := s+"!", res; want != have {
+						t.Errorf("want %v, have %v", want, have)
+					}
+				}(s)
+			}
+			wg.Wait()
+			if have := atomic.LoadInt32(batches); tc.want != have {
+				t.Fatalf("want %d HTTP requests, have %d", tc.want, have)
+			}
+		})
+	}
+}
@@ -8,10 +8,11 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
-	"sync/atomic"
 
 	"github.com/go-kit/kit/endpoint"
 	httptransport "github.com/go-kit/kit/transport/http"
+
+	rpc "github.com/l-vitaly/go-kit/transport/jsonrpc"
 )
 
 // Client wraps a JSON RPC method and provides a method that implements endpoint.Endpoint.
@@ -33,13 +34,6 @@ type Client struct {
 	bufferedStream bool
 }
 
-type clientRequest struct {
-	JSONRPC string          `json:"jsonrpc"`
-	Method  string          `json:"method"`
-	Params  json.RawMessage `json:"params"`
-	ID      interface{}     `json:"id"`
-}
-
 // NewClient constructs a usable Client for a single remote method.
 func NewClient(
 	tgt *url.URL,
@@ -123,9 +117,7 @@ func ClientResponseDecoder(dec DecodeResponseFunc) ClientOption {
 }
 
 // RequestIDGenerator returns an ID for the request.
-type RequestIDGenerator interface {
-	Generate() interface{}
-}
+type RequestIDGenerator = rpc.RequestIDGenerator
 
 // ClientRequestIDGenerator is executed before each request to generate an ID
 // for the request.
@@ -164,11 +156,10 @@ func (c Client) Endpoint() endpoint.Endpoint {
 		if params, err = c.enc(ctx, request); err != nil {
 			return nil, err
 		}
-		rpcReq := clientRequest{
-			JSONRPC: Version,
-			Method:  c.method,
-			Params:  params,
-			ID:      c.requestID.Generate(),
+		id := c.requestID.Generate()
+		rpcReq, err := rpc.NewRequest(c.method, params, id)
+		if err != nil {
+			return nil, err
 		}
 
 		req, err := http.NewRequest("POST", c.tgt.String(), nil)
@@ -202,6 +193,10 @@ func (c Client) Endpoint() endpoint.Endpoint {
 			var rpcRes Response
 			err = json.NewDecoder(resp.Body).Decode(&rpcRes)
 			if err != nil {
+				err = InvalidResponseError{Reason: err.Error()}
+				return nil, err
+			}
+			if err = rpc.ValidateResponse(id, rpcRes); err != nil {
 				return nil, err
 			}
 			for _, f := range c.after {
@@ -221,22 +216,8 @@ func (c Client) Endpoint() endpoint.Endpoint {
 // depending on when an error occurs.
 type ClientFinalizerFunc func(ctx context.Context, err error)
 
-// autoIncrementID is a RequestIDGenerator that generates
-// auto-incrementing integer IDs.
-type autoIncrementID struct {
-	v *uint64
-}
-
 // NewAutoIncrementID returns an auto-incrementing request ID generator,
 // initialised with the given value.
 func NewAutoIncrementID(init uint64) RequestIDGenerator {
-	// Offset by one so that the first generated value = init.
-	v := init - 1
-	return &autoIncrementID{v: &v}
-}
-
-// Generate satisfies RequestIDGenerator
-func (i *autoIncrementID) Generate() interface{} {
-	id := atomic.AddUint64(i.v, 1)
-	return id
+	return rpc.NewAutoIncrementID(init)
 }
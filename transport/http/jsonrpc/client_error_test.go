@@ -0,0 +1,84 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/l-vitaly/go-kit/transport/http/jsonrpc"
+)
+
+type fixedIDGenerator int
+
+func (g fixedIDGenerator) Generate() interface{} { return int(g) }
+
+func TestClientResponseValidation(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		body string
+		test func(error) bool
+	}{
+		{
+			name: "valid",
+			body: `{"jsonrpc":"2.0","result":5,"id":7}`,
+			test: func(err error) bool { return err == nil },
+		},
+		{
+			name: "id mismatch",
+			body: `{"jsonrpc":"2.0","result":5,"id":8}`,
+			test: func(err error) bool {
+				e, ok := err.(jsonrpc.ResponseIDMismatchError)
+				return ok && string(e.Want) == "7" && string(e.Have) == "8"
+			},
+		},
+		{
+			name: "string id",
+			body: `{"jsonrpc":"2.0","result":5,"id":"7"}`,
+			test: func(err error) bool { _, ok := err.(jsonrpc.ResponseIDMismatchError); return ok },
+		},
+		{
+			name: "missing id",
+			body: `{"jsonrpc":"2.0","result":5}`,
+			test: func(err error) bool { _, ok := err.(jsonrpc.ResponseIDMismatchError); return ok },
+		},
+		{
+			name: "null id with error",
+			body: `{"jsonrpc":"2.0","error":{"code":-32700,"message":"parse error"},"id":null}`,
+			test: func(err error) bool { e, ok := err.(jsonrpc.Error); return ok && e.Code == jsonrpc.ParseError },
+		},
+		{
+			name: "wrong version",
+			body: `{"jsonrpc":"1.0","result":5,"id":7}`,
+			test: func(err error) bool { _, ok := err.(jsonrpc.InvalidResponseError); return ok },
+		},
+		{
+			name: "malformed",
+			body: `{"jsonrpc":"2.0",`,
+			test: func(err error) bool { _, ok := err.(jsonrpc.InvalidResponseError); return ok },
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var sent map[string]interface{}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := ioutil.ReadAll(r.Body)
+				_ = json.Unmarshal(b, &sent)
+				_, _ = w.Write([]byte(tc.body))
+			}))
+			defer server.Close()
+
+			u, _ := url.Parse(server.URL)
+			sut := jsonrpc.NewClient(u, "add", jsonrpc.ClientRequestIDGenerator(fixedIDGenerator(7)))
+			_, err := sut.Endpoint()(context.Background(), 5)
+			if !tc.test(err) {
+				t.Errorf("unexpected error: %v (%T)", err, err)
+			}
+			if want, have := jsonrpc.Version, sent["jsonrpc"]; want != have {
+				t.Errorf("jsonrpc: want=%q, have=%v", want, have)
+			}
+		})
+	}
+}
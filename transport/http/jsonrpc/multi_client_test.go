@@ -0,0 +1,66 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/l-vitaly/go-kit/transport/http/jsonrpc"
+)
+
+func TestMultiClient(t *testing.T) {
+	server, u, _ := testBatchServer(t)
+	defer server.Close()
+
+	var (
+		beforeCalls int
+		decodeInt   = func(_ context.Context, res jsonrpc.Response) (interface{}, error) {
+			if res.Error != nil {
+				return nil, *res.Error
+			}
+			var n int
+			err := json.Unmarshal(res.Result, &n)
+			return n, err
+		}
+	)
+	sut := jsonrpc.NewMultiClient(
+		u,
+		jsonrpc.ClientCodecMap{
+			"add":   jsonrpc.ClientCodec{Decode: decodeInt},
+			"upper": jsonrpc.ClientCodec{},
+		},
+		jsonrpc.ClientBefore(func(ctx context.Context, _ *http.Request) context.Context {
+			beforeCalls++
+			return ctx
+		}),
+	)
+
+	endpoints := sut.Endpoints()
+	if want, have := 2, len(endpoints); want != have {
+		t.Fatalf("want %d endpoints, have %d", want, have)
+	}
+
+	sum, err := endpoints["add"](context.Background(), []int{2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 5, sum; want != have {
+		t.Fatalf("want %v, have %v", want, have)
+	}
+
+	s, err := endpoints["upper"](context.Background(), "hey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "hey!", s; want != have {
+		t.Fatalf("want %v, have %v", want, have)
+	}
+
+	if _, err := sut.Endpoint("nope")(context.Background(), nil); err == nil {
+		t.Fatal("Expected method not found error, got none.")
+	}
+	if want, have := 3, beforeCalls; want != have {
+		t.Fatalf("before funcs: want %d calls, have %d", want, have)
+	}
+}
@@ -1,81 +1,44 @@
 package jsonrpc
 
-import "encoding/json"
+import rpc "github.com/l-vitaly/go-kit/transport/jsonrpc"
 
 // Request defines a JSON RPC request from the spec
 // http://www.jsonrpc.org/specification#request_object
-type Request struct {
-	JSONRPC string          `json:"jsonrpc"`
-	Method  string          `json:"method"`
-	Params  json.RawMessage `json:"params"`
-	ID      *RequestID      `json:"id"`
-}
+// ID is nil if the request has no id member, and a null RequestID if its id
+// is null.
+type Request = rpc.Request
 
 // RequestID defines a request ID that can be string, number, or null.
-// An identifier established by the Client that MUST contain a String,
-// Number, or NULL value if included.
-// If it is not included it is assumed to be a notification.
-// The value SHOULD normally not be Null and
-// Numbers SHOULD NOT contain fractional parts.
-type RequestID struct {
-	intValue    int
-	intError    error
-	floatValue  float32
-	floatError  error
-	stringValue string
-	stringError error
-}
+// It keeps the exact JSON it was read from.
+type RequestID = rpc.RequestID
 
-// UnmarshalJSON satisfies json.Unmarshaler
-func (id *RequestID) UnmarshalJSON(b []byte) error {
-	id.intError = json.Unmarshal(b, &id.intValue)
-	id.floatError = json.Unmarshal(b, &id.floatValue)
-	id.stringError = json.Unmarshal(b, &id.stringValue)
+// Constructors of request IDs.
+var (
+	// NewRequestID returns the ID encoding v, which must marshal to a JSON
+	// string, number or null, like the values of a RequestIDGenerator.
+	NewRequestID = rpc.NewRequestID
 
-	return nil
-}
+	// NewInt64RequestID returns a number ID.
+	NewInt64RequestID = rpc.NewInt64RequestID
 
-func (id *RequestID) MarshalJSON() ([]byte, error) {
-	if id.intError == nil {
-		return json.Marshal(id.intValue)
-	} else if id.floatError == nil {
-		return json.Marshal(id.floatValue)
-	} else {
-		return json.Marshal(id.stringValue)
-	}
-}
+	// NewUint64RequestID returns a number ID.
+	NewUint64RequestID = rpc.NewUint64RequestID
 
-// Int returns the ID as an integer value.
-// An error is returned if the ID can't be treated as an int.
-func (id *RequestID) Int() (int, error) {
-	return id.intValue, id.intError
-}
+	// NewStringRequestID returns a string ID.
+	NewStringRequestID = rpc.NewStringRequestID
 
-// Float32 returns the ID as a float value.
-// An error is returned if the ID can't be treated as an float.
-func (id *RequestID) Float32() (float32, error) {
-	return id.floatValue, id.floatError
-}
-
-// String returns the ID as a string value.
-// An error is returned if the ID can't be treated as an string.
-func (id *RequestID) String() (string, error) {
-	return id.stringValue, id.stringError
-}
+	// NewNullRequestID returns a null ID.
+	NewNullRequestID = rpc.NewNullRequestID
+)
 
 // Response defines a JSON RPC response from the spec
 // http://www.jsonrpc.org/specification#response_object
-type Response struct {
-	JSONRPC string          `json:"jsonrpc"`
-	Result  json.RawMessage `json:"result,omitempty"`
-	Error   *Error          `json:"error,omitempty"`
-	ID      *RequestID      `json:"id"`
-}
+type Response = rpc.Response
 
 const (
 	// Version defines the version of the JSON RPC implementation
-	Version string = "2.0"
+	Version = rpc.Version
 
 	// ContentType defines the content type to be served.
-	ContentType string = "application/json; charset=utf-8"
+	ContentType = rpc.ContentType
 )
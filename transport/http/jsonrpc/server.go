@@ -1,22 +1,23 @@
 package jsonrpc
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
-	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"time"
 
 	"github.com/go-kit/kit/log"
 	httptransport "github.com/go-kit/kit/transport/http"
 	"github.com/gorilla/websocket"
-)
 
-type requestIDKeyType struct{}
+	rpc "github.com/l-vitaly/go-kit/transport/jsonrpc"
+)
 
-var RequestIDKey requestIDKeyType
+// requestConcurrencyDefault is the default number of requests of an
+// asynchronous batch handled concurrently.
+const requestConcurrencyDefault = 100
 
 // Server wraps an endpoint and implements http.Handler.
 type Server struct {
@@ -26,7 +27,17 @@ type Server struct {
 	after        []httptransport.ServerResponseFunc
 	errorEncoder ErrorEncoder
 	finalizer    httptransport.ServerFinalizerFunc
+	strict       bool
 	logger       log.Logger
+
+	maxBatchSize       int
+	requestConcurrency int
+	slots              chan struct{} // limits the concurrent endpoint calls
+	requestTimeout     time.Duration
+
+	handler         rpc.Handler
+	dispatcher      *rpc.Dispatcher // handles the requests of a batch in turn
+	asyncDispatcher *rpc.Dispatcher // handles them concurrently
 }
 
 // NewServer constructs a new server, which implements http.Server.
@@ -35,13 +46,23 @@ func NewServer(
 	options ...ServerOption,
 ) *Server {
 	s := &Server{
-		ecm:          ecm,
-		errorEncoder: DefaultErrorEncoder,
-		logger:       log.NewNopLogger(),
+		ecm:                ecm,
+		errorEncoder:       DefaultErrorEncoder,
+		logger:             log.NewNopLogger(),
+		requestConcurrency: requestConcurrencyDefault,
 	}
 	for _, option := range options {
 		option(s)
 	}
+	s.handler = rpc.EndpointHandler(s.ecm, s.errorEncoder, s.logger)
+	dispatcherOptions := []rpc.DispatcherOption{
+		rpc.DispatcherStrict(s.strict),
+		rpc.DispatcherMaxBatchSize(s.maxBatchSize),
+		rpc.DispatcherErrorEncoder(s.errorEncoder),
+		rpc.DispatcherErrorLogger(s.logger),
+	}
+	s.dispatcher = rpc.NewDispatcher(dispatcherOptions...)
+	s.asyncDispatcher = rpc.NewDispatcher(append(dispatcherOptions, rpc.DispatcherConcurrency(s.requestConcurrency))...)
 	return s
 }
 
@@ -68,6 +89,53 @@ func ServerErrorEncoder(ee ErrorEncoder) ServerOption {
 	return func(s *Server) { s.errorEncoder = ee }
 }
 
+// ServerStrict makes the server validate every request against the spec: the
+// jsonrpc member must be "2.0", the method a non-empty string, the params, if
+// any, an array or an object, and the id a string, a number or null. Invalid
+// requests are answered with an InvalidRequestError, one per invalid entry of
+// a batch, and empty batches are rejected.
+// By default, requests are not validated.
+func ServerStrict(strict bool) ServerOption {
+	return func(s *Server) { s.strict = strict }
+}
+
+// ServerMaxBatchSize sets the maximum number of requests of a batch. Larger
+// batches are rejected as a whole with an InvalidRequestError.
+// By default, batches are not limited.
+func ServerMaxBatchSize(n int) ServerOption {
+	return func(s *Server) { s.maxBatchSize = n }
+}
+
+// ServerRequestConcurrency sets the maximum number of requests of a batch
+// handled concurrently when the batch is sent with the "X-Async: on" header.
+// By default, 100 requests are handled concurrently.
+func ServerRequestConcurrency(n int) ServerOption {
+	return func(s *Server) { s.requestConcurrency = n }
+}
+
+// ServerMaxConcurrency sets the maximum number of endpoint calls running at
+// the same time, over all HTTP requests. Requests wait for a free slot until
+// their deadline, and are answered with a TimeoutError past it.
+// By default, endpoint calls are not limited.
+func ServerMaxConcurrency(n int) ServerOption {
+	return func(s *Server) {
+		s.slots = nil
+		if n > 0 {
+			s.slots = make(chan struct{}, n)
+		}
+	}
+}
+
+// ServerRequestTimeout sets the deadline of an HTTP request. Requests of the
+// batch not answered by then get a TimeoutError. Endpoints are expected to
+// return once their context is done; the server only stops waiting for them
+// when the batch is handled asynchronously.
+// By default, requests have no deadline other than the one of the HTTP
+// request context.
+func ServerRequestTimeout(d time.Duration) ServerOption {
+	return func(s *Server) { s.requestTimeout = d }
+}
+
 // ServerErrorLogger is used to log non-terminal errors. By default, no errors
 // are logged. This is intended as a diagnostic measure. Finer-grained control
 // of error handling, including logging in more detail, should be performed in a
@@ -81,7 +149,7 @@ func ServerErrorLogger(logger log.Logger) ServerOption {
 // Users are encouraged to use custom ErrorEncoders to encode HTTP errors to
 // their clients, and will likely want to pass and check for their own error
 // types. See the example shipping/handling service.
-type ErrorEncoder func(ctx context.Context, err error) Response
+type ErrorEncoder = rpc.ErrorEncoder
 
 // ResponseWriter ...
 type ResponseWriter func(ctx context.Context, responses []Response, isBatch bool, w http.ResponseWriter)
@@ -129,19 +197,27 @@ func (s Server) serveHTTP(ctx context.Context, w http.ResponseWriter, r *http.Re
 
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		rpcerr := parseError("JSON could not be read body: " + err.Error())
+		rpcerr := rpc.NewError(ParseError, "JSON could not be read body: "+err.Error())
 		_ = s.logger.Log("err", rpcerr)
 		_, _ = w.Write(s.marshalResponse([]Response{s.errorEncoder(ctx, rpcerr)}, false))
 		return
 	}
 
-	async := r.Header.Get("X-Async") == "on"
+	dispatcher := s.dispatcher
+	if r.Header.Get("X-Async") == "on" {
+		dispatcher = s.asyncDispatcher
+	}
+
+	if s.requestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.requestTimeout)
+		defer cancel()
+	}
 
-	result, isBatch, err := s.rpcCall(ctx, body, async)
+	result, isBatch, err := dispatcher.Dispatch(ctx, body, s.call)
 	if err != nil {
-		rpcerr := parseError("jsonrpc internal error: " + err.Error())
-		_ = s.logger.Log("err", rpcerr)
-		_, _ = w.Write(s.marshalResponse([]Response{s.errorEncoder(ctx, rpcerr)}, false))
+		_ = s.logger.Log("err", err)
+		_, _ = w.Write(s.marshalResponse([]Response{s.errorEncoder(ctx, err)}, false))
 		return
 	}
 
@@ -149,133 +225,56 @@ func (s Server) serveHTTP(ctx context.Context, w http.ResponseWriter, r *http.Re
 		ctx = f(ctx, w)
 	}
 
+	// Nothing is returned for notifications. Dispatch returns a nil result
+	// only when all requests are notifications.
+	if result == nil {
+		w.Header().Del("Content-Type")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
 	_, _ = w.Write(s.marshalResponse(result, isBatch))
 }
 
-func (s Server) rpcCall(ctx context.Context, data []byte, async bool) (result []Response, isBatch bool, err error) {
-	isBatch = true
-	if !bytes.HasPrefix(data, []byte("[")) && !bytes.HasSuffix(data, []byte("]")) {
-		isBatch = false
-		buf := new(bytes.Buffer)
-		buf.WriteString("[")
-		buf.Write(data)
-		buf.WriteString("]")
-		data = buf.Bytes()
-	}
-
-	// Decode the body into an object
-	var reqs []Request
-	err = json.Unmarshal(data, &reqs)
-	if err != nil {
-		return nil, false, err
+// call answers a single request once its endpoint may be called.
+func (s Server) call(ctx context.Context, req Request) Response {
+	if err := s.acquire(ctx); err != nil {
+		_ = s.logger.Log("err", err)
+		return s.errorEncoder(ctx, err)
 	}
+	defer s.release()
+	return s.handler(ctx, req)
+}
 
-	responses := make(chan Response, len(reqs))
-
-	for _, req := range reqs {
-		ctx = context.WithValue(ctx, RequestIDKey, req.ID)
-		// Get the endpoint and codecs from the map using the method
-		// defined in the JSON  object
-		ecm, ok := s.ecm[req.Method]
-		if !ok {
-			err := methodNotFoundError(fmt.Sprintf("Method %s was not found.", req.Method))
-			_ = s.logger.Log("err", err)
-			responses <- s.errorEncoder(ctx, err)
-			continue
-		}
-
-		// Decode the JSON "params"
-		reqParams, err := ecm.Decode(ctx, req.Params)
-		if err != nil {
-			_ = s.logger.Log("err", err)
-			responses <- s.errorEncoder(ctx, err)
-			continue
-		}
-
-		reqFn := func(ctx context.Context, req Request, reqParams interface{}) {
-			// Call the Endpoint with the params
-			response, err := ecm.Endpoint(ctx, reqParams)
-			if err != nil {
-				_ = s.logger.Log("err", err)
-				responses <- s.errorEncoder(ctx, err)
-				return
-			}
-
-			res := Response{
-				ID:      req.ID,
-				JSONRPC: Version,
-			}
-
-			// Encode the response from the Endpoint
-			resParams, err := ecm.Encode(ctx, response)
-			if err != nil {
-				_ = s.logger.Log("err", err)
-				responses <- s.errorEncoder(ctx, err)
-				return
-			}
-			res.Result = resParams
-			responses <- res
+// acquire waits for a free slot to call an endpoint, if the server limits the
+// number of concurrent calls. It fails once ctx is done.
+func (s Server) acquire(ctx context.Context) error {
+	if ctx.Err() == nil {
+		if s.slots == nil {
+			return nil
 		}
-		if async {
-			go reqFn(ctx, req, reqParams)
-		} else {
-			reqFn(ctx, req, reqParams)
+		select {
+		case s.slots <- struct{}{}:
+			return nil
+		case <-ctx.Done():
 		}
 	}
+	return rpc.NewError(TimeoutError, "Request was not answered before its deadline: "+ctx.Err().Error())
+}
 
-	for i := 0; i < len(reqs); i++ {
-		result = append(result, <-responses)
+func (s Server) release() {
+	if s.slots != nil {
+		<-s.slots
 	}
-	return
 }
 
-// DefaultErrorEncoder writes the error to the ResponseWriter,
-// as a json-rpc error response, with an InternalError status code.
+// DefaultErrorEncoder encodes the error as a json-rpc error response, with
+// an InternalError code, for the request ID found in ctx.
 // The Error() string of the error will be used as the response error message.
 // If the error implements ErrorCoder, the provided code will be set on the
 // response error.
-// If the error implements Headerer, the given headers will be set.
 func DefaultErrorEncoder(ctx context.Context, err error) Response {
-	e := Error{
-		Code:    InternalError,
-		Message: err.Error(),
-	}
-	if sc, ok := err.(ErrorCoder); ok {
-		e.Code = sc.ErrorCode()
-	}
-
-	if sc, ok := err.(ErrorData); ok {
-		e.Data = sc.ErrorData()
-	}
-
-	var requestID *RequestID
-	if v := ctx.Value(RequestIDKey); v != nil {
-		requestID = v.(*RequestID)
-	}
-
-	return Response{
-		ID:      requestID,
-		JSONRPC: Version,
-		Error:   &e,
-	}
-}
-
-// ErrorCoder is checked by DefaultErrorEncoder. If an error value implements
-// ErrorCoder, the integer result of ErrorCode() will be used as the JSONRPC
-// error code when encoding the error.
-//
-// By default, InternalError (-32603) is used.
-type ErrorCoder interface {
-	ErrorCode() int
-}
-
-// ErrorData is checked by DefaultErrorEncoder. If an error value implements
-// ErrorData, the interface{} result of ErrorData() will be used as the JSONRPC
-// error data when encoding the error.
-//
-// By default, empty is used.
-type ErrorData interface {
-	ErrorData() interface{}
+	return rpc.DefaultErrorEncoder(ctx, err)
 }
 
 // interceptingWriter intercepts calls to WriteHeader, so that a finalizer
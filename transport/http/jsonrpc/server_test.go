@@ -4,11 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -457,3 +459,272 @@ func testServerForBatch(t *testing.T, async bool) (step func(), resp <-chan *htt
 	}()
 	return func() { stepch <- true }, response
 }
+
+func TestServerNotification(t *testing.T) {
+	var calls int32
+	ecm := jsonrpc.EndpointCodecMap{
+		"add": jsonrpc.EndpointCodec{
+			Endpoint: func(context.Context, interface{}) (interface{}, error) {
+				atomic.AddInt32(&calls, 1)
+				return struct{}{}, nil
+			},
+			Decode: nopDecoder,
+			Encode: nopEncoder,
+		},
+		"fail": jsonrpc.EndpointCodec{
+			Endpoint: func(context.Context, interface{}) (interface{}, error) {
+				atomic.AddInt32(&calls, 1)
+				return nil, errors.New("oof")
+			},
+			Decode: nopDecoder,
+			Encode: nopEncoder,
+		},
+	}
+	server := httptest.NewServer(jsonrpc.NewServer(ecm))
+	defer server.Close()
+
+	for _, tc := range []struct {
+		name  string
+		body  string
+		async bool
+		want  []int // IDs of the expected responses, nil for 204
+	}{
+		{"single", `{"jsonrpc": "2.0", "method": "add", "params": [3, 2]}`, false, nil},
+		{"failing", `{"jsonrpc": "2.0", "method": "fail"}`, false, nil},
+		{"unknown method", `{"jsonrpc": "2.0", "method": "nope"}`, false, nil},
+		{"batch", `[{"jsonrpc": "2.0", "method": "add"}, {"jsonrpc": "2.0", "method": "fail"}]`, false, nil},
+		{"mixed batch", `[{"jsonrpc": "2.0", "method": "add", "id": 1}, {"jsonrpc": "2.0", "method": "fail"}, {"jsonrpc": "2.0", "method": "add", "id": 2}]`, false, []int{1, 2}},
+		{"async mixed batch", `[{"jsonrpc": "2.0", "method": "fail", "id": 1}, {"jsonrpc": "2.0", "method": "add"}, {"jsonrpc": "2.0", "method": "add", "id": 2}]`, true, []int{1, 2}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPost, server.URL, body(tc.body))
+			if tc.async {
+				req.Header.Set("X-Async", "on")
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close() // nolint
+			buf, _ := ioutil.ReadAll(resp.Body)
+
+			if tc.want == nil {
+				if want, have := http.StatusNoContent, resp.StatusCode; want != have {
+					t.Fatalf("want %d, have %d (%s)", want, have, buf)
+				}
+				if len(buf) != 0 {
+					t.Fatalf("want empty body, have %s", buf)
+				}
+				return
+			}
+			res, err := unmarshalResponses(buf)
+			if err != nil {
+				t.Fatalf("Can't decode response. err=%s, body=%s", err, buf)
+			}
+			if want, have := len(tc.want), len(res); want != have {
+				t.Fatalf("want %d responses, have %d (%s)", want, have, buf)
+			}
+			for i, r := range res {
+				if id, _ := r.ID.Int(); id != tc.want[i] {
+					t.Fatalf("JSONRPC ID: want=%d, got=%d", tc.want[i], id)
+				}
+			}
+		})
+	}
+	if want, have := int32(10), atomic.LoadInt32(&calls); want != have {
+		t.Fatalf("endpoint calls: want %d, have %d", want, have)
+	}
+}
+
+func TestServerStrict(t *testing.T) {
+	ecm := jsonrpc.EndpointCodecMap{
+		"add": jsonrpc.EndpointCodec{
+			Endpoint: endpoint.Nop,
+			Decode:   nopDecoder,
+			Encode:   nopEncoder,
+		},
+	}
+	server := httptest.NewServer(jsonrpc.NewServer(ecm, jsonrpc.ServerStrict(true)))
+	defer server.Close()
+
+	post := func(t *testing.T, in string) []byte {
+		t.Helper()
+		resp, err := http.Post(server.URL, "application/json", body(in))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close() // nolint
+		buf, _ := ioutil.ReadAll(resp.Body)
+		return buf
+	}
+
+	for _, tc := range []struct {
+		name string
+		body string
+	}{
+		{"no version", `{"method": "add", "params": [3, 2], "id": 1}`},
+		{"bad version", `{"jsonrpc": "1.0", "method": "add", "params": [3, 2], "id": 1}`},
+		{"empty method", `{"jsonrpc": "2.0", "method": "", "id": 1}`},
+		{"scalar params", `{"jsonrpc": "2.0", "method": "add", "params": 3, "id": 1}`},
+		{"bad id", `{"jsonrpc": "2.0", "method": "add", "id": {}}`},
+		{"not an object", `"add"`},
+		{"invalid notification", `{"jsonrpc": "2.0", "method": 1}`},
+		{"empty batch", ` [ ] `},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			expectErrorCode(t, jsonrpc.InvalidRequestError, post(t, tc.body))
+		})
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		buf := post(t, `{"jsonrpc": "2.0", "method": "add", "params": {"a": 1}, "id": "x"}`)
+		r, err := unmarshalResponse(buf)
+		if err != nil || r.Error != nil {
+			t.Fatalf("want no error, have %v (%s)", err, buf)
+		}
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		buf := post(t, `[1, {"jsonrpc": "2.0", "method": "add", "id": 2}, {"jsonrpc": "2.0", "method": "add", "params": "x", "id": 3}]`)
+		res, err := unmarshalResponses(buf)
+		if err != nil {
+			t.Fatalf("Can't decode response. err=%s, body=%s", err, buf)
+		}
+		if want, have := 3, len(res); want != have {
+			t.Fatalf("want %d responses, have %d (%s)", want, have, buf)
+		}
+		if res[0].ID != nil || res[0].Error == nil || res[0].Error.Code != jsonrpc.InvalidRequestError {
+			t.Fatalf("want invalid request error with null id, have %s", buf)
+		}
+		if res[1].Error != nil {
+			t.Fatalf("want no error, have %s", buf)
+		}
+		if id, _ := res[2].ID.Int(); id != 3 || res[2].Error == nil || res[2].Error.Code != jsonrpc.InvalidRequestError {
+			t.Fatalf("want invalid request error with id 3, have %s", buf)
+		}
+	})
+}
+
+func TestServerLimits(t *testing.T) {
+	var active, maxActive int32
+	ecm := jsonrpc.EndpointCodecMap{
+		"sleep": jsonrpc.EndpointCodec{
+			Endpoint: func(_ context.Context, request interface{}) (interface{}, error) {
+				n := atomic.AddInt32(&active, 1)
+				defer atomic.AddInt32(&active, -1)
+				for {
+					m := atomic.LoadInt32(&maxActive)
+					if n <= m || atomic.CompareAndSwapInt32(&maxActive, m, n) {
+						break
+					}
+				}
+				time.Sleep(request.(time.Duration))
+				return struct{}{}, nil
+			},
+			Decode: func(_ context.Context, msg json.RawMessage) (interface{}, error) {
+				var ms int
+				err := json.Unmarshal(msg, &ms)
+				return time.Duration(ms) * time.Millisecond, err
+			},
+			Encode: nopEncoder,
+		},
+	}
+	batch := func(n, ms int) io.Reader {
+		reqs := make([]string, n)
+		for i := range reqs {
+			reqs[i] = fmt.Sprintf(`{"jsonrpc": "2.0", "method": "sleep", "params": %d, "id": %d}`, ms, i+1)
+		}
+		return body("[" + strings.Join(reqs, ",") + "]")
+	}
+	post := func(t *testing.T, handler http.Handler, in io.Reader) []byte {
+		t.Helper()
+		server := httptest.NewServer(handler)
+		defer server.Close()
+		req, _ := http.NewRequest(http.MethodPost, server.URL, in)
+		req.Header.Set("X-Async", "on")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close() // nolint
+		buf, _ := ioutil.ReadAll(resp.Body)
+		return buf
+	}
+
+	t.Run("max batch size", func(t *testing.T) {
+		buf := post(t, jsonrpc.NewServer(ecm, jsonrpc.ServerMaxBatchSize(2)), batch(3, 0))
+		expectErrorCode(t, jsonrpc.InvalidRequestError, buf)
+	})
+
+	t.Run("request concurrency", func(t *testing.T) {
+		atomic.StoreInt32(&maxActive, 0)
+		buf := post(t, jsonrpc.NewServer(ecm, jsonrpc.ServerRequestConcurrency(2)), batch(6, 10))
+		res, err := unmarshalResponses(buf)
+		if err != nil {
+			t.Fatalf("Can't decode response. err=%s, body=%s", err, buf)
+		}
+		if want, have := 6, len(res); want != have {
+			t.Fatalf("want %d responses, have %d (%s)", want, have, buf)
+		}
+		for i, r := range res {
+			if id, _ := r.ID.Int(); id != i+1 || r.Error != nil {
+				t.Fatalf("response %d: want id %d and no error, have %s", i, i+1, buf)
+			}
+		}
+		if max := atomic.LoadInt32(&maxActive); max > 2 {
+			t.Fatalf("want at most 2 concurrent requests, have %d", max)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		handler := jsonrpc.NewServer(
+			ecm,
+			jsonrpc.ServerMaxConcurrency(1),
+			jsonrpc.ServerRequestTimeout(50*time.Millisecond),
+		)
+		begin := time.Now()
+		buf := post(t, handler, batch(2, 500))
+		if elapsed := time.Since(begin); elapsed > 400*time.Millisecond {
+			t.Fatalf("want the server to stop waiting at the deadline, took %v", elapsed)
+		}
+		res, err := unmarshalResponses(buf)
+		if err != nil {
+			t.Fatalf("Can't decode response. err=%s, body=%s", err, buf)
+		}
+		if want, have := 2, len(res); want != have {
+			t.Fatalf("want %d responses, have %d (%s)", want, have, buf)
+		}
+		for i, r := range res {
+			if r.Error == nil || r.Error.Code != jsonrpc.TimeoutError {
+				t.Fatalf("response %d: want timeout error, have %s", i, buf)
+			}
+		}
+	})
+}
+
+func TestServerRequestID(t *testing.T) {
+	ecm := jsonrpc.EndpointCodecMap{
+		"add": jsonrpc.EndpointCodec{
+			Endpoint: endpoint.Nop,
+			Decode:   nopDecoder,
+			Encode:   nopEncoder,
+		},
+	}
+	server := httptest.NewServer(jsonrpc.NewServer(ecm))
+	defer server.Close()
+
+	for _, id := range []string{`null`, `9007199254740993`, `18446744073709551615`, `"A"`, `1.50`} {
+		resp, err := http.Post(server.URL, "application/json", body(`{"jsonrpc": "2.0", "method": "add", "id": `+id+`}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf, _ := ioutil.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if want, have := http.StatusOK, resp.StatusCode; want != have {
+			t.Fatalf("%s: want %d, have %d (%s)", id, want, have, buf)
+		}
+		if want := `"id":` + id; !strings.Contains(string(buf), want) {
+			t.Errorf("%s: want %s in response, have %s", id, want, buf)
+		}
+	}
+}
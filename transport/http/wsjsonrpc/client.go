@@ -4,18 +4,33 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"io/ioutil"
+	"errors"
+	"io"
 	"net/http"
 	"net/url"
-	"sync/atomic"
+	"sync"
+	"time"
 
 	"github.com/go-kit/kit/endpoint"
-	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/gorilla/websocket"
+
+	rpc "github.com/l-vitaly/go-kit/transport/jsonrpc"
 )
 
+// ErrClientConnClosed is returned by calls that are pending or started on a
+// ClientConn after its WebSocket connection has been closed.
+var ErrClientConnClosed = errors.New("wsjsonrpc: client connection closed")
+
+// ErrDuplicateRequestID is returned by calls and streams started on a
+// ClientConn with the ID of a call or stream still in progress on it.
+var ErrDuplicateRequestID = errors.New("wsjsonrpc: request id already in use on the connection")
+
 // Client wraps a JSON RPC method and provides a method that implements endpoint.Endpoint.
+// All calls made through the Client are multiplexed over a single WebSocket
+// connection, which is dialed on first use and redialed once it is closed.
 type Client struct {
-	client httptransport.HTTPClient
+	dialer *websocket.Dialer
+	header http.Header
 
 	// JSON RPC endpoint URL
 	tgt *url.URL
@@ -23,20 +38,14 @@ type Client struct {
 	// JSON RPC method name.
 	method string
 
-	enc            EncodeRequestFunc
-	dec            DecodeResponseFunc
-	before         []httptransport.RequestFunc
-	after          []httptransport.ClientResponseFunc
-	finalizer      httptransport.ClientFinalizerFunc
-	requestID      RequestIDGenerator
-	bufferedStream bool
-}
+	enc       EncodeRequestFunc
+	dec       DecodeResponseFunc
+	finalizer ClientFinalizerFunc
+	requestID RequestIDGenerator
 
-type clientRequest struct {
-	JSONRPC string          `json:"jsonrpc"`
-	Method  string          `json:"method"`
-	Params  json.RawMessage `json:"params"`
-	ID      interface{}     `json:"id"`
+	connMux sync.Mutex
+	conn    *ClientConn
+	shared  bool
 }
 
 // NewClient constructs a usable Client for a single remote method.
@@ -46,15 +55,11 @@ func NewClient(
 	options ...ClientOption,
 ) *Client {
 	c := &Client{
-		client:         http.DefaultClient,
-		method:         method,
-		tgt:            tgt,
-		enc:            DefaultRequestEncoder,
-		dec:            DefaultResponseDecoder,
-		before:         []httptransport.RequestFunc{},
-		after:          []httptransport.ClientResponseFunc{},
-		requestID:      NewAutoIncrementID(0),
-		bufferedStream: false,
+		dialer: websocket.DefaultDialer,
+		method: method,
+		tgt:    tgt,
+		enc:    DefaultRequestEncoder,
+		dec:    DefaultResponseDecoder,
 	}
 	for _, option := range options {
 		option(c)
@@ -84,28 +89,28 @@ func DefaultResponseDecoder(_ context.Context, res Response) (interface{}, error
 // ClientOption sets an optional parameter for clients.
 type ClientOption func(*Client)
 
-// SetClient sets the underlying HTTP client used for requests.
-// By default, http.DefaultClient is used.
-func SetClient(client httptransport.HTTPClient) ClientOption {
-	return func(c *Client) { c.client = client }
+// SetDialer sets the WebSocket dialer used to connect to the server.
+// By default, websocket.DefaultDialer is used.
+func SetDialer(dialer *websocket.Dialer) ClientOption {
+	return func(c *Client) { c.dialer = dialer }
 }
 
-// ClientBefore sets the RequestFuncs that are applied to the outgoing HTTP
-// request before it's invoked.
-func ClientBefore(before ...httptransport.RequestFunc) ClientOption {
-	return func(c *Client) { c.before = append(c.before, before...) }
+// SetHeader sets the HTTP headers sent with the WebSocket handshake request.
+func SetHeader(header http.Header) ClientOption {
+	return func(c *Client) { c.header = header }
 }
 
-// ClientAfter sets the ClientResponseFuncs applied to the server's HTTP
-// response prior to it being decoded. This is useful for obtaining anything
-// from the response and adding onto the context prior to decoding.
-func ClientAfter(after ...httptransport.ClientResponseFunc) ClientOption {
-	return func(c *Client) { c.after = append(c.after, after...) }
+// SetConn makes the client use an already dialed connection instead of
+// dialing its own. It allows several clients to share one WebSocket, whose
+// calls get distinct IDs from the connection. The connection is not redialed
+// by the client once it is closed.
+func SetConn(conn *ClientConn) ClientOption {
+	return func(c *Client) { c.conn, c.shared = conn, true }
 }
 
-// ClientFinalizer is executed at the end of every HTTP request.
+// ClientFinalizer is executed at the end of every call.
 // By default, no finalizer is registered.
-func ClientFinalizer(f httptransport.ClientFinalizerFunc) ClientOption {
+func ClientFinalizer(f ClientFinalizerFunc) ClientOption {
 	return func(c *Client) { c.finalizer = f }
 }
 
@@ -122,119 +127,348 @@ func ClientResponseDecoder(dec DecodeResponseFunc) ClientOption {
 }
 
 // RequestIDGenerator returns an ID for the request.
-type RequestIDGenerator interface {
-	Generate() interface{}
-}
+type RequestIDGenerator = rpc.RequestIDGenerator
 
 // ClientRequestIDGenerator is executed before each request to generate an ID
-// for the request.
-// By default, AutoIncrementRequestID is used.
+// for the request. The IDs must be unique among the calls in progress on the
+// connection, including those of the other clients sharing it.
+// By default, the IDs are generated by the ClientConn, which keeps them
+// unique.
 func ClientRequestIDGenerator(g RequestIDGenerator) ClientOption {
 	return func(c *Client) { c.requestID = g }
 }
 
-// BufferedStream sets whether the Response.Body is left open, allowing it
-// to be read from later. Useful for transporting a file as a buffered stream.
-func BufferedStream(buffered bool) ClientOption {
-	return func(c *Client) { c.bufferedStream = buffered }
-}
-
 // Endpoint returns a usable endpoint that invokes the remote endpoint.
-func (c Client) Endpoint() endpoint.Endpoint {
+func (c *Client) Endpoint() endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		ctx, cancel := context.WithCancel(ctx)
 		defer cancel()
 
-		var (
-			resp *http.Response
-			err  error
-		)
+		var err error
 		if c.finalizer != nil {
-			defer func() {
-				if resp != nil {
-					ctx = context.WithValue(ctx, httptransport.ContextKeyResponseHeaders, resp.Header)
-					ctx = context.WithValue(ctx, httptransport.ContextKeyResponseSize, resp.ContentLength)
-				}
-				c.finalizer(ctx, err)
-			}()
+			defer func() { c.finalizer(ctx, err) }()
 		}
 
 		var params json.RawMessage
 		if params, err = c.enc(ctx, request); err != nil {
 			return nil, err
 		}
-		rpcReq := clientRequest{
-			JSONRPC: "",
-			Method:  c.method,
-			Params:  params,
-			ID:      c.requestID.Generate(),
-		}
 
-		req, err := http.NewRequest("POST", c.tgt.String(), nil)
+		conn, err := c.connect()
 		if err != nil {
 			return nil, err
 		}
 
-		req.Header.Set("Content-Type", "application/json; charset=utf-8")
-		var b bytes.Buffer
-		req.Body = ioutil.NopCloser(&b)
-		err = json.NewEncoder(&b).Encode(rpcReq)
+		rpcRes, err := conn.Call(ctx, c.method, params, c.nextID(conn))
 		if err != nil {
 			return nil, err
 		}
 
-		for _, f := range c.before {
-			ctx = f(ctx, req)
-		}
+		response, err := c.dec(ctx, rpcRes)
+		return response, err
+	}
+}
 
-		resp, err = c.client.Do(req.WithContext(ctx))
-		if err != nil {
-			return nil, err
+// Close closes the connection dialed by the client. Connections set with
+// SetConn are left open.
+func (c *Client) Close() error {
+	c.connMux.Lock()
+	defer c.connMux.Unlock()
+	if c.conn == nil || c.shared {
+		return nil
+	}
+	return c.conn.Close()
+}
+
+// nextID returns the ID of the next request made on conn.
+func (c *Client) nextID(conn *ClientConn) interface{} {
+	if c.requestID != nil {
+		return c.requestID.Generate()
+	}
+	return conn.requestID.Generate()
+}
+
+func (c *Client) connect() (*ClientConn, error) {
+	c.connMux.Lock()
+	defer c.connMux.Unlock()
+	if c.shared {
+		return c.conn, nil
+	}
+	if c.conn != nil {
+		select {
+		case <-c.conn.Done():
+		default:
+			return c.conn, nil
 		}
+	}
+	conn, err := Dial(c.tgt, c.dialer, c.header)
+	if err != nil {
+		return nil, err
+	}
+	c.conn = conn
+	return conn, nil
+}
+
+// ClientConn is a WebSocket connection to a JSON RPC server. It multiplexes
+// any number of concurrent calls over the socket and matches the responses
+// to their callers by request ID.
+type ClientConn struct {
+	conn    *websocket.Conn
+	send    chan []byte
+	pending map[string]chan Response
+	streams map[string]*ClientStream
+	pendMux sync.Mutex
+
+	// Generates the IDs of the clients which don't set their own.
+	requestID RequestIDGenerator
+
+	notify NotificationHandler
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NotificationHandler is called with the notifications the server sends on a
+// ClientConn, such as the messages of Server.Broadcast and Server.Publish.
+// It's called from the goroutine reading the connection, so it must not
+// block: the responses received after a notification wait for its handler
+// to return.
+type NotificationHandler func(Notification)
+
+// ClientConnOption sets an optional parameter for client connections.
+type ClientConnOption func(*ClientConn)
+
+// ClientConnNotificationHandler sets the handler of the notifications the
+// server sends on the connection.
+// By default, notifications are dropped.
+func ClientConnNotificationHandler(h NotificationHandler) ClientConnOption {
+	return func(cc *ClientConn) { cc.notify = h }
+}
+
+// Dial connects to the JSON RPC server at tgt and starts serving the
+// connection. If dialer is nil, websocket.DefaultDialer is used.
+func Dial(tgt *url.URL, dialer *websocket.Dialer, header http.Header, options ...ClientConnOption) (*ClientConn, error) {
+	if dialer == nil {
+		dialer = websocket.DefaultDialer
+	}
+	conn, _, err := dialer.Dial(tgt.String(), header)
+	if err != nil {
+		return nil, err
+	}
+	cc := &ClientConn{
+		conn:      conn,
+		send:      make(chan []byte, 256),
+		pending:   map[string]chan Response{},
+		streams:   map[string]*ClientStream{},
+		requestID: NewAutoIncrementID(0),
+		done:      make(chan struct{}),
+	}
+	for _, option := range options {
+		option(cc)
+	}
+	go cc.writePump()
+	go cc.readPump()
+	return cc, nil
+}
+
+// Call sends a request for method with the given params and id, and waits
+// for the response with the same id. It returns early if ctx is done or the
+// connection is closed. An id in use by another call or stream of the
+// connection is rejected with ErrDuplicateRequestID.
+func (cc *ClientConn) Call(ctx context.Context, method string, params json.RawMessage, id interface{}) (Response, error) {
+	rid, err := NewRequestID(id)
+	if err != nil {
+		return Response{}, err
+	}
+	key := rid.Key()
+
+	resc := make(chan Response, 1)
+	if err := cc.register(key, resc, nil); err != nil {
+		return Response{}, err
+	}
+	defer func() {
+		cc.pendMux.Lock()
+		delete(cc.pending, key)
+		cc.pendMux.Unlock()
+	}()
+
+	if err := cc.write(ctx, method, params, id); err != nil {
+		return Response{}, err
+	}
+
+	select {
+	case res := <-resc:
+		return res, nil
+	case <-cc.done:
+		return Response{}, ErrClientConnClosed
+	case <-ctx.Done():
+		return Response{}, ctx.Err()
+	}
+}
+
+// register reserves key for the response channel of a call, or for a
+// stream, unless a call or stream in progress already uses it.
+func (cc *ClientConn) register(key string, resc chan Response, stream *ClientStream) error {
+	cc.pendMux.Lock()
+	defer cc.pendMux.Unlock()
+	if _, ok := cc.pending[key]; ok {
+		return ErrDuplicateRequestID
+	}
+	if _, ok := cc.streams[key]; ok {
+		return ErrDuplicateRequestID
+	}
+	if stream != nil {
+		cc.streams[key] = stream
+	} else {
+		cc.pending[key] = resc
+	}
+	return nil
+}
+
+// write queues a request on the connection.
+func (cc *ClientConn) write(ctx context.Context, method string, params json.RawMessage, id interface{}) error {
+	req, err := rpc.NewRequest(method, params, id)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case cc.send <- data:
+		return nil
+	case <-cc.done:
+		return ErrClientConnClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Done returns a channel that is closed when the connection is closed.
+func (cc *ClientConn) Done() <-chan struct{} {
+	return cc.done
+}
 
-		if !c.bufferedStream {
-			defer resp.Body.Close()
+// Close sends a close frame to the server and closes the connection.
+func (cc *ClientConn) Close() error {
+	cc.shutdown()
+	return nil
+}
+
+func (cc *ClientConn) shutdown() {
+	cc.closeOnce.Do(func() { close(cc.done) })
+}
+
+// dispatch hands message, a single JSON value read from the connection, to
+// the call or stream it answers, or to the notification handler if it has
+// no ID.
+func (cc *ClientConn) dispatch(message json.RawMessage) error {
+	var res Response
+	if err := json.Unmarshal(message, &res); err != nil {
+		return err
+	}
+	if res.ID == nil {
+		if cc.notify == nil {
+			return nil
+		}
+		var n Notification
+		if err := json.Unmarshal(message, &n); err != nil {
+			return err
 		}
+		if n.Method != "" {
+			cc.notify(n)
+		}
+		return nil
+	}
+	key := res.ID.Key()
+	cc.pendMux.Lock()
+	stream, isStream := cc.streams[key]
+	resc, ok := cc.pending[key]
+	cc.pendMux.Unlock()
+	if isStream {
+		stream.deliver(res)
+		return nil
+	}
+	if ok {
+		select {
+		case resc <- res:
+		default:
+		}
+	}
+	return nil
+}
 
-		// Decode the body into an object
-		var rpcRes Response
-		err = json.NewDecoder(resp.Body).Decode(&rpcRes)
+func (cc *ClientConn) readPump() {
+	defer func() {
+		cc.shutdown()
+		_ = cc.conn.Close()
+	}()
+	_ = cc.conn.SetReadDeadline(time.Now().Add(pongWait))
+	cc.conn.SetPongHandler(func(string) error {
+		_ = cc.conn.SetReadDeadline(time.Now().Add(pongWait))
+		return nil
+	})
+	for {
+		_, message, err := cc.conn.ReadMessage()
 		if err != nil {
-			return nil, err
+			return
 		}
-
-		for _, f := range c.after {
-			ctx = f(ctx, resp)
+		// The server may join several queued responses into one message.
+		dec := json.NewDecoder(bytes.NewReader(message))
+		for {
+			var msg json.RawMessage
+			if err := dec.Decode(&msg); err != nil {
+				if err != io.EOF {
+					return
+				}
+				break
+			}
+			if err := cc.dispatch(msg); err != nil {
+				return
+			}
 		}
+	}
+}
 
-		return c.dec(ctx, rpcRes)
+func (cc *ClientConn) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		_ = cc.conn.Close()
+	}()
+	for {
+		select {
+		case message := <-cc.send:
+			_ = cc.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := cc.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				cc.shutdown()
+				return
+			}
+		case <-ticker.C:
+			_ = cc.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := cc.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				cc.shutdown()
+				return
+			}
+		case <-cc.done:
+			_ = cc.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			_ = cc.conn.WriteMessage(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+			return
+		}
 	}
 }
 
-// ClientFinalizerFunc can be used to perform work at the end of a client HTTP
-// request, after the response is returned. The principal
-// intended use is for error logging. Additional response parameters are
-// provided in the context under keys with the ContextKeyResponse prefix.
-// Note: err may be nil. There maybe also no additional response parameters
-// depending on when an error occurs.
+// ClientFinalizerFunc can be used to perform work at the end of a client
+// call, after the response is returned. The principal intended use is for
+// error logging.
+// Note: err may be nil.
 type ClientFinalizerFunc func(ctx context.Context, err error)
 
-// autoIncrementID is a RequestIDGenerator that generates
-// auto-incrementing integer IDs.
-type autoIncrementID struct {
-	v *uint64
-}
-
 // NewAutoIncrementID returns an auto-incrementing request ID generator,
 // initialised with the given value.
 func NewAutoIncrementID(init uint64) RequestIDGenerator {
-	// Offset by one so that the first generated value = init.
-	v := init - 1
-	return &autoIncrementID{v: &v}
-}
-
-// Generate satisfies RequestIDGenerator
-func (i *autoIncrementID) Generate() interface{} {
-	id := atomic.AddUint64(i.v, 1)
-	return id
+	return rpc.NewAutoIncrementID(init)
 }
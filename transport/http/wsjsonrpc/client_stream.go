@@ -0,0 +1,235 @@
+package wsjsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+)
+
+// ErrClientStreamOverflow is returned by ClientStream.Recv when results were
+// pushed by the server faster than they were received, and the stream was
+// ended rather than holding up the other calls of the connection.
+var ErrClientStreamOverflow = errors.New("wsjsonrpc: client stream overflow")
+
+// clientStreamBuffer is the number of results of a stream held until they
+// are received.
+const clientStreamBuffer = 16
+
+// ClientStream is the client side of a subscription to a stream method
+// served through an EndpointCodecStreamMap. Results pushed by the server's
+// Stream.Write are read with Recv, and follow-up params are delivered to the
+// server's Stream.Read channel with Send.
+type ClientStream struct {
+	ctx    context.Context
+	cc     *ClientConn
+	method string
+	id     interface{}
+	key    string
+
+	enc EncodeRequestFunc
+	dec DecodeResponseFunc
+
+	head   *Response
+	frames chan Response
+	acked  bool // the server acknowledged the stream request
+
+	done      chan struct{}
+	closeOnce sync.Once
+	err       error // why the stream ended, if not by the server
+}
+
+// Stream opens a subscription to the client's method with the given request
+// as the initial params. The returned ClientStream is bound to ctx.
+func (c *Client) Stream(ctx context.Context, request interface{}) (*ClientStream, error) {
+	params, err := c.enc(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := c.connect()
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := conn.OpenStream(ctx, c.method, params, c.nextID(conn))
+	if err != nil {
+		return nil, err
+	}
+	s.enc = c.enc
+	s.dec = c.dec
+	return s, nil
+}
+
+// OpenStream sends a request for the stream method with the given params and
+// id, and waits for the server to acknowledge it. A JSON RPC error returned
+// by the server in place of the acknowledgement is returned as the error. An
+// id in use by another call or stream of the connection is rejected with
+// ErrDuplicateRequestID.
+// The stream decodes results with DefaultResponseDecoder and encodes follow-up
+// params with DefaultRequestEncoder.
+func (cc *ClientConn) OpenStream(ctx context.Context, method string, params json.RawMessage, id interface{}) (*ClientStream, error) {
+	rid, err := NewRequestID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &ClientStream{
+		ctx:    ctx,
+		cc:     cc,
+		method: method,
+		id:     id,
+		key:    rid.Key(),
+		enc:    DefaultRequestEncoder,
+		dec:    DefaultResponseDecoder,
+		frames: make(chan Response, clientStreamBuffer),
+		done:   make(chan struct{}),
+	}
+
+	if err := cc.register(s.key, nil, s); err != nil {
+		return nil, err
+	}
+
+	if err := cc.write(ctx, method, params, id); err != nil {
+		s.release()
+		return nil, err
+	}
+
+	res, err := s.next()
+	if err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+	if res.Error != nil {
+		s.release()
+		return nil, *res.Error
+	}
+	if s.acked = isAck(res); !s.acked {
+		// Methods which are not streams answer with a plain response.
+		s.head = &res
+	}
+	return s, nil
+}
+
+// Recv waits for the next result pushed by the server and decodes it with
+// the stream's DecodeResponseFunc. It returns io.EOF once the stream has
+// ended, and the JSON RPC error if the server ended it with one.
+func (s *ClientStream) Recv() (interface{}, error) {
+	for {
+		res, err := s.next()
+		if err != nil {
+			return nil, err
+		}
+		if isAck(res) {
+			s.acked = true
+			continue
+		}
+		if !res.Stream {
+			// The terminal frame of the stream, or the plain response of a
+			// method which is not a stream, is the last one for the request
+			// ID. The server acknowledges stream requests before any frame.
+			s.release()
+			if res.Error == nil && s.acked {
+				return nil, io.EOF
+			}
+		}
+		return s.dec(s.ctx, res)
+	}
+}
+
+// Send encodes request with the stream's EncodeRequestFunc and delivers it to
+// the server's Stream.Read channel.
+func (s *ClientStream) Send(request interface{}) error {
+	params, err := s.enc(s.ctx, request)
+	if err != nil {
+		return err
+	}
+	select {
+	case <-s.done:
+		return io.ErrClosedPipe
+	default:
+	}
+	return s.cc.write(s.ctx, s.method, params, s.id)
+}
+
+// Close unsubscribes from the stream and stops receiving its results.
+func (s *ClientStream) Close() error {
+	select {
+	case <-s.done:
+		return nil
+	default:
+	}
+	s.release()
+	return s.cc.write(context.Background(), UnsubscribeMethod, nil, s.id)
+}
+
+// release stops receiving results for the stream.
+func (s *ClientStream) release() {
+	s.end(nil)
+}
+
+// end stops receiving results for the stream, which Recv reports with err
+// once the results already received are handed out.
+func (s *ClientStream) end(err error) {
+	s.closeOnce.Do(func() {
+		s.err = err
+		s.cc.pendMux.Lock()
+		delete(s.cc.streams, s.key)
+		s.cc.pendMux.Unlock()
+		close(s.done)
+	})
+}
+
+// Done returns a channel that is closed when the stream has ended.
+func (s *ClientStream) Done() <-chan struct{} {
+	return s.done
+}
+
+func (s *ClientStream) next() (Response, error) {
+	if s.head != nil {
+		res := *s.head
+		s.head = nil
+		return res, nil
+	}
+	// Frames received before the stream or connection ended are still
+	// handed out.
+	select {
+	case res := <-s.frames:
+		return res, nil
+	default:
+	}
+	select {
+	case res := <-s.frames:
+		return res, nil
+	case <-s.done:
+		if s.err != nil {
+			return Response{}, s.err
+		}
+		return Response{}, io.EOF
+	case <-s.cc.done:
+		return Response{}, ErrClientConnClosed
+	case <-s.ctx.Done():
+		return Response{}, s.ctx.Err()
+	}
+}
+
+// deliver hands res to the stream without blocking, as it's called by the
+// read loop of the connection. A stream whose buffer is full is ended with
+// ErrClientStreamOverflow, and unsubscribed from.
+func (s *ClientStream) deliver(res Response) {
+	select {
+	case s.frames <- res:
+		return
+	case <-s.done:
+		return
+	default:
+	}
+	s.end(ErrClientStreamOverflow)
+	go func() { _ = s.cc.write(context.Background(), UnsubscribeMethod, nil, s.id) }()
+}
+
+// isAck reports whether res only acknowledges a stream request.
+func isAck(res Response) bool {
+	return res.Stream && res.Error == nil && res.Result == nil
+}
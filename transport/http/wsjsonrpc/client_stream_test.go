@@ -0,0 +1,212 @@
+package wsjsonrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/l-vitaly/go-kit/transport/http/wsjsonrpc"
+)
+
+func TestClientStream(t *testing.T) {
+	ecms := wsjsonrpc.EndpointCodecStreamMap{
+		"echo": wsjsonrpc.EndpointCodecStream{
+			Endpoint: func(ctx context.Context, request interface{}) (interface{}, error) {
+				stream := request.(*wsjsonrpc.Stream)
+				_ = stream.Write("hello")
+				for params := range stream.Read() {
+					var s string
+					if err := json.Unmarshal(params, &s); err != nil {
+						return nil, err
+					}
+					_ = stream.Write(s)
+				}
+				return nil, nil
+			},
+			Decode: func(_ context.Context, _ json.RawMessage, stream *wsjsonrpc.Stream) (interface{}, error) {
+				return stream, nil
+			},
+		},
+	}
+	server := httptest.NewServer(wsjsonrpc.NewServer(wsjsonrpc.EndpointCodecMap{}, ecms))
+	defer server.Close()
+
+	sut := wsjsonrpc.NewClient(mustParse("ws"+strings.TrimPrefix(server.URL, "http")), "echo")
+	defer sut.Close()
+
+	stream, err := sut.Stream(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	for _, want := range []string{"hello", "foo", "bar"} {
+		if want != "hello" {
+			if err := stream.Send(want); err != nil {
+				t.Fatal(err)
+			}
+		}
+		have, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want != have {
+			t.Fatalf("want=%s, have=%v", want, have)
+		}
+	}
+}
+
+func TestClientStreamMethodNotFound(t *testing.T) {
+	server, u := testWSServer(wsjsonrpc.EndpointCodecMap{})
+	defer server.Close()
+
+	sut := wsjsonrpc.NewClient(u, "echo")
+	defer sut.Close()
+
+	_, err := sut.Stream(context.Background(), nil)
+	if err == nil {
+		t.Fatal("Expected error, got none.")
+	}
+	if e, ok := err.(wsjsonrpc.Error); !ok || e.Code != wsjsonrpc.MethodNotFoundError {
+		t.Fatalf("want method not found error, have %v", err)
+	}
+}
+
+func TestClientConcurrentStreamsSameMethod(t *testing.T) {
+	ecms := wsjsonrpc.EndpointCodecStreamMap{
+		"prefix": wsjsonrpc.EndpointCodecStream{
+			Endpoint: func(ctx context.Context, request interface{}) (interface{}, error) {
+				stream := request.(*streamRequest)
+				for params := range stream.Read() {
+					var s string
+					if err := json.Unmarshal(params, &s); err != nil {
+						return nil, err
+					}
+					_ = stream.Write(stream.prefix + s)
+				}
+				return nil, nil
+			},
+			Decode: func(_ context.Context, params json.RawMessage, stream *wsjsonrpc.Stream) (interface{}, error) {
+				req := &streamRequest{Stream: stream}
+				err := json.Unmarshal(params, &req.prefix)
+				return req, err
+			},
+		},
+	}
+	server := httptest.NewServer(wsjsonrpc.NewServer(wsjsonrpc.EndpointCodecMap{}, ecms))
+	defer server.Close()
+
+	sut := wsjsonrpc.NewClient(mustParse("ws"+strings.TrimPrefix(server.URL, "http")), "prefix")
+	defer sut.Close()
+
+	a, err := sut.Stream(context.Background(), "a:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	b, err := sut.Stream(context.Background(), "b:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	for _, test := range []struct {
+		stream *wsjsonrpc.ClientStream
+		send   string
+		want   string
+	}{
+		{a, "1", "a:1"},
+		{b, "2", "b:2"},
+		{a, "3", "a:3"},
+	} {
+		if err := test.stream.Send(test.send); err != nil {
+			t.Fatal(err)
+		}
+		have, err := test.stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if test.want != have {
+			t.Fatalf("want=%s, have=%v", test.want, have)
+		}
+	}
+}
+
+type streamRequest struct {
+	*wsjsonrpc.Stream
+	prefix string
+}
+
+func TestClientStreamOverflow(t *testing.T) {
+	ecm := wsjsonrpc.EndpointCodecMap{
+		"ping": wsjsonrpc.EndpointCodec{
+			Endpoint: func(context.Context, interface{}) (interface{}, error) { return "pong", nil },
+			Decode:   func(context.Context, json.RawMessage) (interface{}, error) { return nil, nil },
+			Encode:   func(_ context.Context, res interface{}) (json.RawMessage, error) { return json.Marshal(res) },
+		},
+	}
+	ecms := wsjsonrpc.EndpointCodecStreamMap{
+		"flood": wsjsonrpc.EndpointCodecStream{
+			Endpoint: func(ctx context.Context, request interface{}) (interface{}, error) {
+				stream := request.(*wsjsonrpc.Stream)
+				for i := 0; i < 100; i++ {
+					if err := stream.Write(i); err != nil {
+						break
+					}
+				}
+				<-ctx.Done()
+				return nil, nil
+			},
+			Decode: func(_ context.Context, _ json.RawMessage, stream *wsjsonrpc.Stream) (interface{}, error) {
+				return stream, nil
+			},
+		},
+	}
+	server := httptest.NewServer(wsjsonrpc.NewServer(ecm, ecms))
+	defer server.Close()
+
+	conn, err := wsjsonrpc.Dial(mustParse("ws"+strings.TrimPrefix(server.URL, "http")), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	stream, err := wsjsonrpc.NewClient(nil, "flood", wsjsonrpc.SetConn(conn)).Stream(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-stream.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream not ended on overflow")
+	}
+
+	// A stream which is not received from does not hold up the connection.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	res, err := wsjsonrpc.NewClient(nil, "ping", wsjsonrpc.SetConn(conn)).Endpoint()(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "pong", res; want != have {
+		t.Errorf("want %v, have %v", want, have)
+	}
+
+	// The results received are handed out before the overflow is reported.
+	var n int
+	for {
+		if _, err = stream.Recv(); err != nil {
+			break
+		}
+		n++
+	}
+	if want, have := wsjsonrpc.ErrClientStreamOverflow, err; want != have {
+		t.Errorf("want %v, have %v", want, have)
+	}
+	if n == 0 {
+		t.Error("want buffered results received")
+	}
+}
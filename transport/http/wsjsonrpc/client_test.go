@@ -3,69 +3,59 @@ package wsjsonrpc_test
 import (
 	"context"
 	"encoding/json"
-	"io"
-	"io/ioutil"
-	"net/http"
+	"errors"
+	"fmt"
 	"net/http/httptest"
 	"net/url"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
-	"github.com/go-kit/kit/transport/http/jsonrpc"
+	"github.com/l-vitaly/go-kit/transport/http/wsjsonrpc"
 )
 
-type TestResponse struct {
-	Body   io.ReadCloser
-	String string
-}
-
-func TestCanCallBeforeFunc(t *testing.T) {
-	called := false
-	u, _ := url.Parse("http://senseye.io/jsonrpc")
-	sut := jsonrpc.NewClient(
-		u,
-		"add",
-		jsonrpc.ClientBefore(func(ctx context.Context, req *http.Request) context.Context {
-			called = true
-			return ctx
-		}),
-	)
-
-	sut.Endpoint()(context.TODO(), "foo")
-
-	if !called {
-		t.Fatal("Expected client before func to be called. Wasn't.")
-	}
-}
-
 type staticIDGenerator int
 
 func (g staticIDGenerator) Generate() interface{} { return g }
 
+func testWSServer(ecm wsjsonrpc.EndpointCodecMap) (*httptest.Server, *url.URL) {
+	server := httptest.NewServer(wsjsonrpc.NewServer(ecm, wsjsonrpc.EndpointCodecStreamMap{}))
+	return server, mustParse("ws" + strings.TrimPrefix(server.URL, "http"))
+}
+
 func TestClientHappyPath(t *testing.T) {
+	type addRequest struct {
+		A int
+		B int
+	}
+
 	var (
-		afterCalledKey    = "AC"
-		beforeHeaderKey   = "BF"
-		beforeHeaderValue = "beforeFuncWozEre"
-		testbody          = `{"jsonrpc":"2.0", "result":5}`
-		requestBody       []byte
-		beforeFunc        = func(ctx context.Context, r *http.Request) context.Context {
-			r.Header.Add(beforeHeaderKey, beforeHeaderValue)
-			return ctx
-		}
-		encode = func(ctx context.Context, req interface{}) (json.RawMessage, error) {
-			return json.Marshal(req)
-		}
-		afterFunc = func(ctx context.Context, r *http.Response) context.Context {
-			return context.WithValue(ctx, afterCalledKey, true)
+		paramsAtServer addRequest
+		idAtServer     int
+		ecm            = wsjsonrpc.EndpointCodecMap{
+			"add": wsjsonrpc.EndpointCodec{
+				Endpoint: func(ctx context.Context, request interface{}) (interface{}, error) {
+					req := request.(addRequest)
+					idAtServer, _ = ctx.Value(wsjsonrpc.RequestIDKey).(*wsjsonrpc.RequestID).Int()
+					paramsAtServer = req
+					return req.A + req.B, nil
+				},
+				Decode: func(_ context.Context, msg json.RawMessage) (interface{}, error) {
+					var req addRequest
+					err := json.Unmarshal(msg, &req)
+					return req, err
+				},
+				Encode: func(_ context.Context, res interface{}) (json.RawMessage, error) {
+					return json.Marshal(res)
+				},
+			},
 		}
 		finalizerCalled = false
 		fin             = func(ctx context.Context, err error) {
 			finalizerCalled = true
 		}
-		decode = func(ctx context.Context, res jsonrpc.Response) (interface{}, error) {
-			if ac := ctx.Value(afterCalledKey); ac == nil {
-				t.Fatal("after not called")
-			}
+		decode = func(ctx context.Context, res wsjsonrpc.Response) (interface{}, error) {
 			var result int
 			err := json.Unmarshal(res.Result, &result)
 			if err != nil {
@@ -78,40 +68,19 @@ func TestClientHappyPath(t *testing.T) {
 		gen    = staticIDGenerator(wantID)
 	)
 
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Header.Get(beforeHeaderKey) != beforeHeaderValue {
-			t.Fatal("Header not set by before func.")
-		}
-
-		b, err := ioutil.ReadAll(r.Body)
-		if err != nil && err != io.EOF {
-			t.Fatal(err)
-		}
-		requestBody = b
-
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(testbody))
-	}))
+	server, u := testWSServer(ecm)
+	defer server.Close()
 
-	sut := jsonrpc.NewClient(
-		mustParse(server.URL),
+	sut := wsjsonrpc.NewClient(
+		u,
 		"add",
-		jsonrpc.ClientRequestEncoder(encode),
-		jsonrpc.ClientResponseDecoder(decode),
-		jsonrpc.ClientBefore(beforeFunc),
-		jsonrpc.ClientAfter(afterFunc),
-		jsonrpc.ClientRequestIDGenerator(gen),
-		jsonrpc.ClientFinalizer(fin),
-		jsonrpc.SetClient(http.DefaultClient),
-		jsonrpc.BufferedStream(false),
+		wsjsonrpc.ClientResponseDecoder(decode),
+		wsjsonrpc.ClientRequestIDGenerator(gen),
+		wsjsonrpc.ClientFinalizer(fin),
 	)
+	defer sut.Close()
 
-	type addRequest struct {
-		A int
-		B int
-	}
-
-	in := addRequest{2, 2}
+	in := addRequest{2, 3}
 
 	result, err := sut.Endpoint()(context.Background(), in)
 	if err != nil {
@@ -124,102 +93,70 @@ func TestClientHappyPath(t *testing.T) {
 	if ri != 5 {
 		t.Fatalf("want=5, got=%d", ri)
 	}
-
-	var requestAtServer jsonrpc.Request
-	err = json.Unmarshal(requestBody, &requestAtServer)
-	if err != nil {
-		t.Fatal(err)
-	}
-	if id, _ := requestAtServer.ID.Int(); id != wantID {
-		t.Fatalf("Request ID at server: want=%d, got=%d", wantID, id)
-	}
-
-	var paramsAtServer addRequest
-	err = json.Unmarshal(requestAtServer.Params, &paramsAtServer)
-	if err != nil {
-		t.Fatal(err)
+	if idAtServer != wantID {
+		t.Fatalf("Request ID at server: want=%d, got=%d", wantID, idAtServer)
 	}
-
 	if paramsAtServer != in {
 		t.Fatalf("want=%+v, got=%+v", in, paramsAtServer)
 	}
-
 	if !finalizerCalled {
 		t.Fatal("Expected finalizer to be called. Wasn't.")
 	}
 }
 
-func TestCanUseDefaults(t *testing.T) {
-	var (
-		testbody    = `{"jsonrpc":"2.0", "result":"boogaloo"}`
-		requestBody []byte
-	)
-
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		b, err := ioutil.ReadAll(r.Body)
-		if err != nil && err != io.EOF {
-			t.Fatal(err)
-		}
-		requestBody = b
-
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write([]byte(testbody))
-	}))
-
-	sut := jsonrpc.NewClient(
-		mustParse(server.URL),
-		"add",
-	)
-
-	type addRequest struct {
-		A int
-		B int
+func TestClientConcurrentCalls(t *testing.T) {
+	ecm := wsjsonrpc.EndpointCodecMap{
+		"echo": wsjsonrpc.EndpointCodec{
+			Endpoint: func(_ context.Context, request interface{}) (interface{}, error) { return request, nil },
+			Decode: func(_ context.Context, msg json.RawMessage) (interface{}, error) {
+				var n int
+				err := json.Unmarshal(msg, &n)
+				return n, err
+			},
+			Encode: func(_ context.Context, res interface{}) (json.RawMessage, error) {
+				return json.Marshal(res)
+			},
+		},
 	}
-
-	in := addRequest{2, 2}
-
-	result, err := sut.Endpoint()(context.Background(), in)
-	if err != nil {
-		t.Fatal(err)
-	}
-	rs, ok := result.(string)
-	if !ok {
-		t.Fatalf("result is not string: (%T)%+v", result, result)
-	}
-	if rs != "boogaloo" {
-		t.Fatalf("want=boogaloo, got=%s", rs)
-	}
-
-	var requestAtServer jsonrpc.Request
-	err = json.Unmarshal(requestBody, &requestAtServer)
-	if err != nil {
-		t.Fatal(err)
-	}
-	var paramsAtServer addRequest
-	err = json.Unmarshal(requestAtServer.Params, &paramsAtServer)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	if paramsAtServer != in {
-		t.Fatalf("want=%+v, got=%+v", in, paramsAtServer)
+	server, u := testWSServer(ecm)
+	defer server.Close()
+
+	sut := wsjsonrpc.NewClient(u, "echo")
+	defer sut.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			result, err := sut.Endpoint()(context.Background(), i)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if want, have := float64(i), result; want != have {
+				t.Errorf("want=%v, have=%v", want, have)
+			}
+		}(i)
 	}
+	wg.Wait()
 }
 
 func TestClientCanHandleJSONRPCError(t *testing.T) {
-	var testbody = `{
-		"jsonrpc": "2.0",
-		"error": {
-			"code": -32603,
-			"message": "Bad thing happened."
-		}
-	}`
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(testbody))
-	}))
+	ecm := wsjsonrpc.EndpointCodecMap{
+		"add": wsjsonrpc.EndpointCodec{
+			Endpoint: func(context.Context, interface{}) (interface{}, error) {
+				return nil, errors.New("Bad thing happened.")
+			},
+			Decode: func(context.Context, json.RawMessage) (interface{}, error) { return struct{}{}, nil },
+			Encode: func(context.Context, interface{}) (json.RawMessage, error) { return []byte("[]"), nil },
+		},
+	}
+	server, u := testWSServer(ecm)
+	defer server.Close()
 
-	sut := jsonrpc.NewClient(mustParse(server.URL), "add")
+	sut := wsjsonrpc.NewClient(u, "add")
+	defer sut.Close()
 
 	_, err := sut.Endpoint()(context.Background(), 5)
 	if err == nil {
@@ -243,7 +180,7 @@ func TestClientCanHandleJSONRPCError(t *testing.T) {
 	}
 
 	{
-		want := -32603
+		want := wsjsonrpc.InternalError
 		got := ec.ErrorCode()
 		if got != want {
 			t.Fatalf("error code: want=%d, got=%d", want, got)
@@ -251,8 +188,105 @@ func TestClientCanHandleJSONRPCError(t *testing.T) {
 	}
 }
 
+func TestClientConnClosed(t *testing.T) {
+	server, u := testWSServer(wsjsonrpc.EndpointCodecMap{})
+	defer server.Close()
+
+	conn, err := wsjsonrpc.Dial(u, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.Close()
+
+	sut := wsjsonrpc.NewClient(u, "add", wsjsonrpc.SetConn(conn))
+	if _, err := sut.Endpoint()(context.Background(), 5); err != wsjsonrpc.ErrClientConnClosed {
+		t.Fatalf("want=%v, got=%v", wsjsonrpc.ErrClientConnClosed, err)
+	}
+}
+
+func TestClientsSharingConn(t *testing.T) {
+	var (
+		arrived = make(chan struct{}, 1)
+		release = make(chan struct{})
+		decode  = func(_ context.Context, msg json.RawMessage) (interface{}, error) {
+			var s string
+			err := json.Unmarshal(msg, &s)
+			return s, err
+		}
+		encode = func(_ context.Context, res interface{}) (json.RawMessage, error) { return json.Marshal(res) }
+	)
+	server, u := testWSServer(wsjsonrpc.EndpointCodecMap{
+		"echo": wsjsonrpc.EndpointCodec{
+			Endpoint: func(_ context.Context, request interface{}) (interface{}, error) {
+				time.Sleep(time.Millisecond)
+				return request, nil
+			},
+			Decode: decode,
+			Encode: encode,
+		},
+		"block": wsjsonrpc.EndpointCodec{
+			Endpoint: func(context.Context, interface{}) (interface{}, error) {
+				arrived <- struct{}{}
+				<-release
+				return "done", nil
+			},
+			Decode: decode,
+			Encode: encode,
+		},
+	})
+	defer server.Close()
+
+	conn, err := wsjsonrpc.Dial(u, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Clients sharing a connection get distinct IDs from it.
+	var wg sync.WaitGroup
+	for _, name := range []string{"a", "b"} {
+		echo := wsjsonrpc.NewClient(u, "echo", wsjsonrpc.SetConn(conn)).Endpoint()
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(want string) {
+				defer wg.Done()
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				have, err := echo(ctx, want)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if want != have {
+					t.Errorf("want %v, have %v", want, have)
+				}
+			}(fmt.Sprint(name, i))
+		}
+	}
+	wg.Wait()
+
+	// Explicit IDs already in use on the connection are rejected.
+	blocked := make(chan error, 1)
+	go func() {
+		_, err := wsjsonrpc.NewClient(u, "block", wsjsonrpc.SetConn(conn), wsjsonrpc.ClientRequestIDGenerator(staticIDGenerator(5))).Endpoint()(context.Background(), "")
+		blocked <- err
+	}()
+	<-arrived
+	dup := wsjsonrpc.NewClient(u, "echo", wsjsonrpc.SetConn(conn), wsjsonrpc.ClientRequestIDGenerator(staticIDGenerator(5)))
+	if _, err := dup.Endpoint()(context.Background(), "x"); err != wsjsonrpc.ErrDuplicateRequestID {
+		t.Errorf("call: want %v, have %v", wsjsonrpc.ErrDuplicateRequestID, err)
+	}
+	if _, err := dup.Stream(context.Background(), "x"); err != wsjsonrpc.ErrDuplicateRequestID {
+		t.Errorf("stream: want %v, have %v", wsjsonrpc.ErrDuplicateRequestID, err)
+	}
+	close(release)
+	if err := <-blocked; err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestDefaultAutoIncrementer(t *testing.T) {
-	sut := jsonrpc.NewAutoIncrementID(0)
+	sut := wsjsonrpc.NewAutoIncrementID(0)
 	var want uint64
 	for ; want < 100; want++ {
 		got := sut.Generate()
@@ -1,36 +1,26 @@
 package wsjsonrpc
 
-import (
-	"encoding/json"
-
-	"context"
-
-	"github.com/go-kit/kit/endpoint"
-)
+import rpc "github.com/l-vitaly/go-kit/transport/jsonrpc"
 
 // Server-Side Codec
 
 // EndpointCodec defines a server Endpoint and its associated codecs
-type EndpointCodec struct {
-	Endpoint endpoint.Endpoint
-	Decode   DecodeRequestFunc
-	Encode   EncodeResponseFunc
-}
+type EndpointCodec = rpc.EndpointCodec
 
 // EndpointCodecMap maps the Request.Method to the proper EndpointCodec
-type EndpointCodecMap map[string]EndpointCodec
+type EndpointCodecMap = rpc.EndpointCodecMap
 
 // DecodeRequestFunc extracts a user-domain request object from raw JSON
 // It's designed to be used in JSON RPC servers, for server-side endpoints.
 // One straightforward DecodeRequestFunc could be something that unmarshals
 // JSON from the request body to the concrete request type.
-type DecodeRequestFunc func(context.Context, json.RawMessage) (request interface{}, err error)
+type DecodeRequestFunc = rpc.DecodeRequestFunc
 
 // EncodeResponseFunc encodes the passed response object to a JSON RPC result.
 // It's designed to be used in HTTP servers, for server-side endpoints.
 // One straightforward EncodeResponseFunc could be something that JSON encodes
 // the object directly.
-type EncodeResponseFunc func(context.Context, interface{}) (response json.RawMessage, err error)
+type EncodeResponseFunc = rpc.EncodeResponseFunc
 
 // Client-Side Codec
 
@@ -38,11 +28,11 @@ type EncodeResponseFunc func(context.Context, interface{}) (response json.RawMes
 // It's designed to be used in JSON RPC clients, for client-side
 // endpoints. One straightforward EncodeResponseFunc could be something that
 // JSON encodes the object directly.
-type EncodeRequestFunc func(context.Context, interface{}) (request json.RawMessage, err error)
+type EncodeRequestFunc = rpc.EncodeRequestFunc
 
 // DecodeResponseFunc extracts a user-domain response object from an JSON RPC
 // response object. It's designed to be used in JSON RPC clients, for
 // client-side endpoints. It is the responsibility of this function to decide
 // whether any error present in the JSON RPC response should be surfaced to the
 // client endpoint.
-type DecodeResponseFunc func(context.Context, Response) (response interface{}, err error)
+type DecodeResponseFunc = rpc.DecodeResponseFunc
@@ -0,0 +1,95 @@
+package wsjsonrpc
+
+import (
+	"errors"
+	"time"
+)
+
+// SendPolicy decides what happens to a message queued for a connection whose
+// send buffer is full, typically because the peer reads slower than the
+// server writes.
+type SendPolicy int
+
+const (
+	// SendBlock waits for room in the send buffer until the send timeout
+	// expires, and drops the message after that. It is the default.
+	SendBlock SendPolicy = iota
+
+	// SendDropOldest drops the oldest queued message to make room.
+	SendDropOldest
+
+	// SendDropNewest drops the message being queued.
+	SendDropNewest
+
+	// SendDisconnect drops the message and closes the connection.
+	SendDisconnect
+)
+
+// ErrSendBufferFull is returned, for instance by Stream.Write, when a message
+// is dropped because of the send policy.
+var ErrSendBufferFull = errors.New("wsjsonrpc: send buffer full")
+
+// errSendCancelled is returned by enqueue when cancel is closed before the
+// message could be queued.
+var errSendCancelled = errors.New("wsjsonrpc: send cancelled")
+
+// enqueue queues message for the peer according to the server's send policy.
+// If wait is false, as for the hub, which must never block, SendBlock drops
+// the message at once. enqueue gives up if cancel or the connection is done.
+func (c *wsClient) enqueue(message []byte, wait bool, cancel <-chan struct{}) error {
+	select {
+	case <-c.kicked:
+		return ErrSendBufferFull
+	default:
+	}
+	select {
+	case c.send <- message:
+		return nil
+	default:
+	}
+
+	switch c.s.sendPolicy {
+	case SendBlock:
+		if wait {
+			timer := time.NewTimer(c.s.sendTimeout())
+			defer timer.Stop()
+			select {
+			case c.send <- message:
+				return nil
+			case <-timer.C:
+			case <-cancel:
+				return errSendCancelled
+			case <-c.done:
+				return errSendCancelled
+			}
+		}
+	case SendDropOldest:
+		for i := 0; i < 2; i++ {
+			select {
+			case <-c.send:
+				c.s.droppedFrames.Add(1)
+			default:
+			}
+			select {
+			case c.send <- message:
+				return nil
+			default:
+			}
+		}
+	case SendDisconnect:
+		c.kickOnce.Do(func() {
+			close(c.kicked)
+			_ = c.conn.Close()
+		})
+	}
+	c.s.droppedFrames.Add(1)
+	_ = c.s.logger.Log("err", ErrSendBufferFull)
+	return ErrSendBufferFull
+}
+
+func (s *Server) sendTimeout() time.Duration {
+	if s.sendWait > 0 {
+		return s.sendWait
+	}
+	return s.writeWait
+}
@@ -0,0 +1,101 @@
+package wsjsonrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/metrics/generic"
+	"github.com/gorilla/websocket"
+
+	"github.com/l-vitaly/go-kit/transport/http/wsjsonrpc"
+)
+
+func TestServerSendPolicy(t *testing.T) {
+	// Frames big enough to fill the socket buffers of a client that does not
+	// read.
+	payload := strings.Repeat("x", 64<<10)
+
+	for _, tc := range []struct {
+		name    string
+		policy  wsjsonrpc.SendPolicy
+		wantErr bool
+	}{
+		{"block", wsjsonrpc.SendBlock, true},
+		{"drop oldest", wsjsonrpc.SendDropOldest, false},
+		{"drop newest", wsjsonrpc.SendDropNewest, true},
+		{"disconnect", wsjsonrpc.SendDisconnect, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				dropped = generic.NewCounter("dropped")
+				errc    = make(chan error, 1)
+				ctxc    = make(chan context.Context, 1)
+			)
+			ecms := wsjsonrpc.EndpointCodecStreamMap{
+				"sub": wsjsonrpc.EndpointCodecStream{
+					Endpoint: func(_ context.Context, request interface{}) (interface{}, error) {
+						stream := request.(*wsjsonrpc.Stream)
+						ctxc <- stream.Context()
+						// Write until the first frame is dropped, which
+						// only ends the writes of SendDropOldest.
+						for i := 0; i < 2000 && dropped.Value() == 0; i++ {
+							if err := stream.Write(payload); err != nil {
+								errc <- err
+								return nil, nil
+							}
+						}
+						errc <- nil
+						return nil, nil
+					},
+					Decode: func(_ context.Context, _ json.RawMessage, stream *wsjsonrpc.Stream) (interface{}, error) {
+						return stream, nil
+					},
+				},
+			}
+			handler := wsjsonrpc.NewServer(
+				wsjsonrpc.EndpointCodecMap{},
+				ecms,
+				wsjsonrpc.ServerSendBufferSize(1),
+				wsjsonrpc.ServerSendPolicy(tc.policy, 50*time.Millisecond),
+				wsjsonrpc.ServerDroppedFrames(dropped),
+			)
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			ws := dialTestServer(t, server)
+			defer ws.Close()
+			if err := ws.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","method":"sub","id":1}`)); err != nil {
+				t.Fatal(err)
+			}
+
+			var err error
+			select {
+			case err = <-errc:
+			case <-time.After(10 * time.Second):
+				t.Fatal("stream writes did not return")
+			}
+			if !tc.wantErr {
+				if err != nil {
+					t.Fatalf("want no error, have %v", err)
+				}
+			} else if err != wsjsonrpc.ErrSendBufferFull {
+				t.Fatalf("want=%v, have=%v", wsjsonrpc.ErrSendBufferFull, err)
+			}
+			if dropped.Value() == 0 {
+				t.Fatal("want dropped frames to be counted")
+			}
+
+			if tc.policy == wsjsonrpc.SendDisconnect {
+				select {
+				case <-(<-ctxc).Done():
+				case <-time.After(5 * time.Second):
+					t.Fatal("stream not cancelled after disconnect")
+				}
+			}
+		})
+	}
+}
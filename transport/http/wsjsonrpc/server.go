@@ -4,22 +4,26 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
-	"strconv"
 	"sync"
 	"time"
 
 	"github.com/go-kit/kit/log/level"
 
 	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/discard"
 	httptransport "github.com/go-kit/kit/transport/http"
 	"github.com/gorilla/websocket"
-)
 
-type requestIDKeyType struct{}
+	rpc "github.com/l-vitaly/go-kit/transport/jsonrpc"
+)
 
-var RequestIDKey requestIDKeyType
+// ErrServerClosed is returned by the Server's Broadcast and Publish methods
+// after a call to Shutdown.
+var ErrServerClosed = errors.New("wsjsonrpc: server closed")
 
 const (
 	// Time allowed to write a message to the peer.
@@ -34,9 +38,14 @@ const (
 	// Maximum message size allowed from peer.
 	maxMessageSize = 512
 
-	workersDefault = 100
+	// Number of messages queued for a peer.
+	sendBufferSize = 256
 
-	workerBufferDefault = 20
+	// I/O buffer sizes of the WebSocket connection.
+	readBufferSize  = 1024
+	writeBufferSize = 1024
+
+	workersDefault = 100
 )
 
 var (
@@ -49,44 +58,153 @@ type wsClient struct {
 	s         *Server
 	conn      *websocket.Conn
 	send      chan []byte
-	stream    map[string]*Stream
+	done      chan struct{}
+	stream    map[string]*Stream // active streams by request ID
 	streamMux sync.RWMutex
+	streamWG  sync.WaitGroup
+
+	kicked   chan struct{} // closed when SendDisconnect drops the connection
+	kickOnce sync.Once
+
+	session *Session
 }
 
 func (c *wsClient) readPump() {
+	var readErr error
 	defer func() {
-		c.s.unregister <- c
-		_ = c.conn.Close()
+		if c.s.shuttingDown() {
+			// The read deadline set by stopReading is not an error.
+			readErr = nil
+			c.drainStreams()
+		}
+		close(c.done)
+		c.closeStreams()
+		for _, f := range c.s.onDisconnect {
+			f(c.ctx, c.session, readErr)
+		}
+		// The writer flushes the queued messages, sends the close frame and
+		// closes the connection once the hub has closed c.send.
+		select {
+		case c.s.unregister <- c:
+		case <-c.s.stopped:
+			_ = c.conn.Close()
+		}
 	}()
-	c.conn.SetReadLimit(maxMessageSize)
-	_ = c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetReadLimit(c.s.maxMessageSize)
+	_ = c.conn.SetReadDeadline(time.Now().Add(c.s.pongWait))
 	c.conn.SetPongHandler(func(string) error {
-		_ = c.conn.SetReadDeadline(time.Now().Add(pongWait))
+		if !c.s.shuttingDown() {
+			_ = c.conn.SetReadDeadline(time.Now().Add(c.s.pongWait))
+		}
 		return nil
 	})
-	for {
+	for !c.s.shuttingDown() {
 		_, message, err := c.conn.ReadMessage()
 		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				_ = level.Error(c.s.logger).Log("err", err)
+			select {
+			case <-c.kicked:
+				readErr = ErrSendBufferFull
+			default:
+				if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+					_ = level.Error(c.s.logger).Log("err", err)
+				}
+				if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+					readErr = err
+				}
 			}
 			break
 		}
 		message = bytes.TrimSpace(bytes.Replace(message, newline, space, -1))
 
-		result, isBatch, err := c.s.rpcCall(c.ctx, c, message, false)
+		result, isBatch, err := c.s.dispatcher.Dispatch(c.ctx, message, c.handleRequest)
 		if err != nil {
 			_ = c.s.logger.Log("err", err)
-			c.send <- c.s.marshalResponse([]Response{c.s.errorEncoder(c.ctx, err)}, isBatch)
+			_ = c.enqueue(c.s.marshalResponse([]Response{c.s.errorEncoder(c.ctx, err)}, isBatch), true, nil)
+			continue
+		}
+
+		// Nothing is sent back for notifications.
+		if result == nil {
+			continue
+		}
+		_ = c.enqueue(c.s.marshalResponse(result, isBatch), true, nil)
+		c.streamsReady(result)
+	}
+}
+
+// streamsReady lets the streams acknowledged in responses, which have just
+// been queued, start writing frames.
+func (c *wsClient) streamsReady(responses []Response) {
+	for _, res := range responses {
+		if !res.Stream || res.ID == nil {
 			continue
 		}
+		c.streamMux.Lock()
+		stream, ok := c.stream[reqID2Str(res.ID)]
+		c.streamMux.Unlock()
+		if ok {
+			stream.markReady()
+		}
+	}
+}
+
+// stopReading makes the pending or next read of the connection fail, so that
+// readPump returns once its in-flight requests have been answered.
+func (c *wsClient) stopReading() {
+	_ = c.conn.SetReadDeadline(time.Now())
+}
+
+// drainStreams waits for the active streams of the connection to end, or
+// for the shutdown to be forced.
+func (c *wsClient) drainStreams() {
+	drained := make(chan struct{})
+	go func() {
+		c.streamWG.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-c.s.force:
+	}
+}
+
+// closeStreams ends all active streams of the connection without notifying
+// the peer, cancelling their endpoint contexts.
+func (c *wsClient) closeStreams() {
+	c.streamMux.Lock()
+	streams := make([]*Stream, 0, len(c.stream))
+	for _, stream := range c.stream {
+		streams = append(streams, stream)
+	}
+	c.streamMux.Unlock()
+	for _, stream := range streams {
+		stream.end(nil)
+	}
+}
 
-		c.send <- c.s.marshalResponse(result, isBatch)
+// connContext carries the values of the upgrade request context for the
+// lifetime of the connection. The request context itself is cancelled as soon
+// as ServeHTTP returns, while connContext is done when the connection closes.
+type connContext struct {
+	context.Context
+	done <-chan struct{}
+}
+
+func (ctx connContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+
+func (ctx connContext) Done() <-chan struct{} { return ctx.done }
+
+func (ctx connContext) Err() error {
+	select {
+	case <-ctx.done:
+		return context.Canceled
+	default:
+		return nil
 	}
 }
 
 func (c *wsClient) writePump() {
-	ticker := time.NewTicker(pingPeriod)
+	ticker := time.NewTicker(c.s.pingPeriod)
 	defer func() {
 		ticker.Stop()
 		_ = c.conn.Close()
@@ -94,10 +212,14 @@ func (c *wsClient) writePump() {
 	for {
 		select {
 		case message, ok := <-c.send:
-			_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			_ = c.conn.SetWriteDeadline(time.Now().Add(c.s.writeWait))
 			if !ok {
 				// The hub closed the channel.
-				_ = c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				code := websocket.CloseNormalClosure
+				if c.s.shuttingDown() {
+					code = websocket.CloseGoingAway
+				}
+				_ = c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(code, ""))
 				return
 			}
 
@@ -108,16 +230,26 @@ func (c *wsClient) writePump() {
 			_, _ = w.Write(message)
 
 			// Add queued chat messages to the current websocket message.
+			// The queue may shrink meanwhile under SendDropOldest.
 			n := len(c.send)
+		queued:
 			for i := 0; i < n; i++ {
-				_, _ = w.Write(newline)
-				_, _ = w.Write(<-c.send)
+				select {
+				case message, ok := <-c.send:
+					if !ok {
+						break queued
+					}
+					_, _ = w.Write(newline)
+					_, _ = w.Write(message)
+				default:
+					break queued
+				}
 			}
 			if err := w.Close(); err != nil {
 				return
 			}
 		case <-ticker.C:
-			_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			_ = c.conn.SetWriteDeadline(time.Now().Add(c.s.writeWait))
 			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				return
 			}
@@ -125,43 +257,41 @@ func (c *wsClient) writePump() {
 	}
 }
 
-type Stream struct {
-	streamRead chan []byte
-	reqID      *RequestID
-	c          *wsClient
-}
-
-func (s *Stream) Read() chan []byte {
-	return s.streamRead
-}
-
-func (s *Stream) Write(v interface{}) error {
-	result, err := json.Marshal(v)
-	if err != nil {
-		return err
-	}
-	s.c.send <- s.c.s.marshalResponse([]Response{{
-		JSONRPC: "2.0",
-		Result:  result,
-		ID:      s.reqID,
-		Stream:  true,
-	}}, false)
-	return nil
-}
-
 // Server wraps an endpoint and implements http.Handler.
 type Server struct {
 	upgrader     websocket.Upgrader
 	ecm          EndpointCodecMap
 	ecms         EndpointCodecStreamMap
 	before       []httptransport.RequestFunc
+	onConnect    []ConnectFunc
+	onDisconnect []DisconnectFunc
 	errorEncoder ErrorEncoder
 	workers      int
-	workerBuffer int
+	handler      rpc.Handler
+	dispatcher   *rpc.Dispatcher
+
+	writeWait      time.Duration
+	pongWait       time.Duration
+	pingPeriod     time.Duration
+	maxMessageSize int64
+	sendBufferSize int
+	strict         bool
+	sendPolicy     SendPolicy
+	sendWait       time.Duration
+	droppedFrames  metrics.Counter
 
 	clients    map[*wsClient]bool
 	register   chan *wsClient
 	unregister chan *wsClient
+	topics     map[string]map[*wsClient]bool
+	subscribe  chan topicSubscription
+	broadcast  chan topicMessage
+
+	quit      chan struct{} // closed when Shutdown is called
+	quitOnce  sync.Once
+	force     chan struct{} // closed when the Shutdown context is done
+	forceOnce sync.Once
+	stopped   chan struct{} // closed when the hub has stopped
 
 	logger log.Logger
 }
@@ -173,23 +303,43 @@ func NewServer(
 	options ...ServerOption,
 ) *Server {
 	s := &Server{
-		ecm:          ecm,
-		ecms:         ecms,
-		errorEncoder: DefaultErrorEncoder,
-		logger:       log.NewNopLogger(),
-		workers:      workersDefault,
-		workerBuffer: workerBufferDefault,
+		ecm:            ecm,
+		ecms:           ecms,
+		errorEncoder:   DefaultErrorEncoder,
+		logger:         log.NewNopLogger(),
+		workers:        workersDefault,
+		writeWait:      writeWait,
+		pongWait:       pongWait,
+		maxMessageSize: maxMessageSize,
+		sendBufferSize: sendBufferSize,
+		droppedFrames:  discard.NewCounter(),
 		upgrader: websocket.Upgrader{
-			ReadBufferSize:  1024,
-			WriteBufferSize: 1024,
+			ReadBufferSize:  readBufferSize,
+			WriteBufferSize: writeBufferSize,
 		},
 		register:   make(chan *wsClient),
 		unregister: make(chan *wsClient),
 		clients:    make(map[*wsClient]bool),
+		topics:     make(map[string]map[*wsClient]bool),
+		subscribe:  make(chan topicSubscription),
+		broadcast:  make(chan topicMessage),
+		quit:       make(chan struct{}),
+		force:      make(chan struct{}),
+		stopped:    make(chan struct{}),
 	}
 	for _, option := range options {
 		option(s)
 	}
+	if s.pingPeriod <= 0 || s.pingPeriod >= s.pongWait {
+		s.pingPeriod = (s.pongWait * 9) / 10
+	}
+	s.handler = rpc.EndpointHandler(s.ecm, s.errorEncoder, s.logger)
+	s.dispatcher = rpc.NewDispatcher(
+		rpc.DispatcherStrict(s.strict),
+		rpc.DispatcherConcurrency(s.workers),
+		rpc.DispatcherErrorEncoder(s.errorEncoder),
+		rpc.DispatcherErrorLogger(s.logger),
+	)
 	go s.run()
 	return s
 }
@@ -203,6 +353,18 @@ func ServerBefore(before ...httptransport.RequestFunc) ServerOption {
 	return func(s *Server) { s.before = append(s.before, before...) }
 }
 
+// ServerOnConnect functions are executed, in order, on every new connection
+// before any message is read from it. See ConnectFunc.
+func ServerOnConnect(f ...ConnectFunc) ServerOption {
+	return func(s *Server) { s.onConnect = append(s.onConnect, f...) }
+}
+
+// ServerOnDisconnect functions are executed, in order, when a connection is
+// closed. See DisconnectFunc.
+func ServerOnDisconnect(f ...DisconnectFunc) ServerOption {
+	return func(s *Server) { s.onDisconnect = append(s.onDisconnect, f...) }
+}
+
 // ServerAfter functions are executed on the HTTP response writer after the
 // endpoint is invoked, but before anything is written to the client.
 //func ServerAfter(after ...httptransport.ServerResponseFunc) ServerOption {
@@ -217,10 +379,100 @@ func ServerErrorEncoder(ee ErrorEncoder) ServerOption {
 	return func(s *Server) { s.errorEncoder = ee }
 }
 
+// Workers sets the maximum number of requests of a batch handled
+// concurrently. Connections read one message at a time, so it also bounds the
+// number of goroutines handling the requests of a connection. Stream
+// endpoints run in goroutines of their own and are not counted.
+// By default, 100 workers are used.
 func Workers(workers int) ServerOption {
 	return func(s *Server) { s.workers = workers }
 }
 
+// ServerWriteWait sets the time allowed to write a message to the peer.
+// By default, 10 seconds are allowed.
+func ServerWriteWait(d time.Duration) ServerOption {
+	return func(s *Server) { s.writeWait = d }
+}
+
+// ServerPongWait sets the time allowed to read the next pong message from the
+// peer. By default, 60 seconds are allowed.
+func ServerPongWait(d time.Duration) ServerOption {
+	return func(s *Server) { s.pongWait = d }
+}
+
+// ServerPingPeriod sets the period of the pings sent to the peer. It must be
+// less than the pong wait, otherwise, and by default, 9/10 of the pong wait
+// is used.
+func ServerPingPeriod(d time.Duration) ServerOption {
+	return func(s *Server) { s.pingPeriod = d }
+}
+
+// ServerMaxMessageSize sets the maximum size in bytes of a message read from
+// the peer. The connection is closed if a larger message is received.
+// By default, 512 bytes are allowed.
+func ServerMaxMessageSize(size int64) ServerOption {
+	return func(s *Server) { s.maxMessageSize = size }
+}
+
+// ServerSendBufferSize sets the number of outgoing messages queued for each
+// connection. By default, 256 messages are queued.
+func ServerSendBufferSize(size int) ServerOption {
+	return func(s *Server) { s.sendBufferSize = size }
+}
+
+// ServerSendPolicy sets what happens to a message queued for a connection
+// whose send buffer is full. For SendBlock, timeout is how long to wait for
+// room in the buffer. Zero, the default, waits as long as the write wait.
+// Broadcast and Publish never wait: with SendBlock their messages are dropped
+// for connections whose buffer is full.
+// By default, SendBlock is used.
+func ServerSendPolicy(policy SendPolicy, timeout time.Duration) ServerOption {
+	return func(s *Server) { s.sendPolicy, s.sendWait = policy, timeout }
+}
+
+// ServerDroppedFrames sets the counter incremented for every message dropped
+// because of the send policy. By default, dropped messages are not counted.
+func ServerDroppedFrames(counter metrics.Counter) ServerOption {
+	return func(s *Server) { s.droppedFrames = counter }
+}
+
+// ServerBufferSizes sets the I/O buffer sizes of the WebSocket connections.
+// By default, both are 1024 bytes.
+func ServerBufferSizes(readBufferSize, writeBufferSize int) ServerOption {
+	return func(s *Server) {
+		s.upgrader.ReadBufferSize = readBufferSize
+		s.upgrader.WriteBufferSize = writeBufferSize
+	}
+}
+
+// ServerCheckOrigin sets the func used to check the Origin header of the
+// upgrade request. By default, only same-origin requests are accepted.
+func ServerCheckOrigin(f func(r *http.Request) bool) ServerOption {
+	return func(s *Server) { s.upgrader.CheckOrigin = f }
+}
+
+// ServerSubprotocols sets the server's supported subprotocols in order of
+// preference. The first one also requested by the client is negotiated.
+func ServerSubprotocols(protocols ...string) ServerOption {
+	return func(s *Server) { s.upgrader.Subprotocols = protocols }
+}
+
+// ServerEnableCompression makes the server negotiate per message compression
+// (RFC 7692) with clients that support it.
+func ServerEnableCompression(enable bool) ServerOption {
+	return func(s *Server) { s.upgrader.EnableCompression = enable }
+}
+
+// ServerStrict makes the server validate every request against the spec: the
+// jsonrpc member must be "2.0", the method a non-empty string, the params, if
+// any, an array or an object, and the id a string, a number or null. Invalid
+// requests are answered with an InvalidRequestError, one per invalid entry of
+// a batch, and empty batches are rejected.
+// By default, requests are not validated.
+func ServerStrict(strict bool) ServerOption {
+	return func(s *Server) { s.strict = strict }
+}
+
 // ServerErrorLogger is used to log non-terminal errors. By default, no errors
 // are logged. This is intended as a diagnostic measure. Finer-grained control
 // of error handling, including logging in more detail, should be performed in a
@@ -234,13 +486,18 @@ func ServerErrorLogger(logger log.Logger) ServerOption {
 // Users are encouraged to use custom ErrorEncoders to encode HTTP errors to
 // their clients, and will likely want to pass and check for their own error
 // types. See the example shipping/handling service.
-type ErrorEncoder func(ctx context.Context, err error) Response
+type ErrorEncoder = rpc.ErrorEncoder
 
 // ResponseWriter ...
 type ResponseWriter func(ctx context.Context, responses []Response, isBatch bool, w http.ResponseWriter)
 
 // ServeHTTP implements http.Handler.
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.shuttingDown() {
+		http.Error(w, ErrServerClosed.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
 	ctx := r.Context()
 
 	for _, f := range s.before {
@@ -253,9 +510,35 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	c := &wsClient{ctx: ctx, s: s, conn: conn, send: make(chan []byte, 256), stream: map[string]*Stream{}}
+	session := newSession()
+	ctx = context.WithValue(ctx, ConnIDKey, session.id)
+	ctx = context.WithValue(ctx, sessionKey, session)
 
-	s.register <- c
+	done := make(chan struct{})
+	c := &wsClient{ctx: connContext{ctx, done}, s: s, conn: conn, send: make(chan []byte, s.sendBufferSize), done: done, kicked: make(chan struct{}), stream: map[string]*Stream{}, session: session}
+
+	for _, f := range s.onConnect {
+		if err := f(c.ctx, session); err != nil {
+			_ = s.logger.Log("err", err)
+			_ = conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.ClosePolicyViolation, err.Error()),
+				time.Now().Add(s.writeWait))
+			close(done)
+			_ = conn.Close()
+			return
+		}
+	}
+
+	select {
+	case s.register <- c:
+	case <-s.stopped:
+		close(done)
+		_ = conn.Close()
+		for _, f := range s.onDisconnect {
+			f(c.ctx, session, ErrServerClosed)
+		}
+		return
+	}
 
 	go c.writePump()
 	go c.readPump()
@@ -270,188 +553,164 @@ func (s *Server) marshalResponse(responses []Response, isBatch bool) (data []byt
 	return
 }
 
-func (s *Server) requestWorker(ctx context.Context, c *wsClient, requests chan Request, responses chan Response) {
-	for req := range requests {
-
-		c.streamMux.Lock()
-		if stream, ok := c.stream[req.Method]; ok {
-			c.streamMux.Unlock()
-			stream.streamRead <- req.Params
-			continue
-		}
-		c.streamMux.Unlock()
-
-		ctx = context.WithValue(ctx, RequestIDKey, req.ID)
-		// Get the endpoint and codecs from the map using the method
-		// defined in the JSON  object
-		ecm, ok := s.ecm[req.Method]
+// handleRequest handles a single request of a message and returns its
+// response.
+func (c *wsClient) handleRequest(ctx context.Context, req Request) Response {
+	s := c.s
+
+	// A request carrying the ID of an active stream delivers follow-up
+	// params to that stream, or ends it.
+	c.streamMux.Lock()
+	stream, ok := c.stream[reqID2Str(req.ID)]
+	c.streamMux.Unlock()
+	if req.Method == UnsubscribeMethod {
 		if !ok {
-			if ecm, ok := s.ecms[req.Method]; ok {
-				stream := &Stream{
-					reqID:      req.ID,
-					c:          c,
-					streamRead: make(chan []byte),
-				}
-
-				c.streamMux.Lock()
-				c.stream[req.Method] = stream
-				c.streamMux.Unlock()
-
-				// Decode the JSON "params"
-				reqParams, err := ecm.Decode(ctx, req.Params, stream)
-				if err != nil {
-					_ = s.logger.Log("err", err)
-					responses <- s.errorEncoder(ctx, err)
-					continue
-				}
-				go func() {
-					responses <- Response{
-						ID:      req.ID,
-						JSONRPC: Version,
-						Stream:  true,
-					}
-					_, _ = ecm.Endpoint(ctx, reqParams)
-				}()
-				continue
-			} else {
-				err := methodNotFoundError(fmt.Sprintf("Method %s was not found.", req.Method))
-				_ = s.logger.Log("err", err)
-				responses <- s.errorEncoder(ctx, err)
-				continue
-			}
-		}
-
-		// Decode the JSON "params"
-		reqParams, err := ecm.Decode(ctx, req.Params)
-		if err != nil {
+			err := rpc.NewError(InvalidParamsError, fmt.Sprintf("Stream %s is not open.", reqID2Str(req.ID)))
 			_ = s.logger.Log("err", err)
-			responses <- s.errorEncoder(ctx, err)
-			continue
-		}
-
-		response, err := ecm.Endpoint(ctx, reqParams)
-		if err != nil {
-			_ = s.logger.Log("err", err)
-			responses <- s.errorEncoder(ctx, err)
-			return
+			return s.errorEncoder(ctx, err)
 		}
-		res := Response{
+		stream.end(nil)
+		return Response{
 			ID:      req.ID,
 			JSONRPC: Version,
+			Result:  terminalResult,
 		}
-		// Encode the response from the Endpoint
-		resParams, err := ecm.Encode(ctx, response)
-		if err != nil {
-			_ = s.logger.Log("err", err)
-			responses <- s.errorEncoder(ctx, err)
-			return
+	}
+	if ok && req.ID != nil {
+		stream.deliver(req.Params)
+		return Response{
+			ID:      req.ID,
+			JSONRPC: Version,
+			Stream:  true,
 		}
-		res.Result = resParams
-		responses <- res
 	}
-}
 
-func (s *Server) rpcCall(ctx context.Context, c *wsClient, data []byte, async bool) (result []Response, isBatch bool, err error) {
-	isBatch = true
-	if len(data) > 0 && !bytes.HasPrefix(data, []byte("[")) && !bytes.HasSuffix(data, []byte("]")) {
-		isBatch = false
-		buf := new(bytes.Buffer)
-		buf.WriteString("[")
-		buf.Write(data)
-		buf.WriteString("]")
-		data = buf.Bytes()
+	if req.Method == TopicSubscribeMethod || req.Method == TopicUnsubscribeMethod {
+		return s.topicRequest(ctx, c, req)
 	}
 
-	// Decode the body into an object
-	var reqs []Request
-	err = json.Unmarshal(data, &reqs)
-	if err != nil {
-		return nil, false, err
+	ecms, ok := s.ecms[req.Method]
+	if _, isEndpoint := s.ecm[req.Method]; isEndpoint || !ok {
+		// The handler answers unknown methods with a MethodNotFoundError.
+		return s.handler(ctx, req)
+	}
+
+	if req.ID == nil {
+		err := rpc.NewError(InvalidRequestError, fmt.Sprintf("Stream method %s requires a request id.", req.Method))
+		_ = s.logger.Log("err", err)
+		return s.errorEncoder(ctx, err)
 	}
+	stream = newStream(ctx, c, req.ID)
+	c.streamWG.Add(1)
 
-	requests := make(chan Request, s.workerBuffer)
-	responses := make(chan Response, s.workerBuffer)
+	c.streamMux.Lock()
+	c.stream[reqID2Str(req.ID)] = stream
+	c.streamMux.Unlock()
 
-	if async {
-		go s.requestWorker(ctx, c, requests, responses)
-	} else {
-		for w := 1; w <= s.workers; w++ {
-			go s.requestWorker(ctx, c, requests, responses)
+	// Decode the JSON "params"
+	reqParams, err := ecms.Decode(stream.ctx, req.Params, stream)
+	if err != nil {
+		stream.end(nil)
+		_ = s.logger.Log("err", err)
+		return s.errorEncoder(ctx, err)
+	}
+	go func() {
+		if _, err := ecms.Endpoint(stream.ctx, reqParams); err != nil {
+			_ = s.logger.Log("err", err)
+			_ = stream.CloseWithError(err)
+			return
 		}
+		_ = stream.Close()
+	}()
+	return Response{
+		ID:      req.ID,
+		JSONRPC: Version,
+		Stream:  true,
 	}
+}
 
-	for _, req := range reqs {
-		requests <- req
+// Shutdown gracefully shuts down the server. It stops accepting new
+// connections and stops reading requests from the open ones. Then, for every
+// connection, it waits for the in-flight requests to be answered and for the
+// active streams to end, sends a close frame and closes it. Shutdown returns
+// once all connections are closed and the hub has stopped.
+//
+// If ctx is done first, the active streams are cancelled and Shutdown returns
+// the context's error. The remaining connections are closed as soon as their
+// in-flight requests return.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.quitOnce.Do(func() { close(s.quit) })
+	select {
+	case <-s.stopped:
+		return nil
+	case <-ctx.Done():
+		s.forceOnce.Do(func() { close(s.force) })
+		return ctx.Err()
 	}
-	close(requests)
+}
 
-	for i := 0; i < len(reqs); i++ {
-		result = append(result, <-responses)
+func (s *Server) shuttingDown() bool {
+	select {
+	case <-s.quit:
+		return true
+	default:
+		return false
 	}
-	return
 }
 
 func (s *Server) run() {
+	defer close(s.stopped)
+	quit := s.quit
 	for {
 		select {
 		case client := <-s.register:
 			s.clients[client] = true
+			if quit == nil {
+				client.stopReading()
+			}
 		case client := <-s.unregister:
 			if _, ok := s.clients[client]; ok {
 				delete(s.clients, client)
+				for topic, clients := range s.topics {
+					delete(clients, client)
+					if len(clients) == 0 {
+						delete(s.topics, topic)
+					}
+				}
 				close(client.send)
 			}
+			if quit == nil && len(s.clients) == 0 {
+				return
+			}
+		case <-quit:
+			quit = nil
+			if len(s.clients) == 0 {
+				return
+			}
+			for client := range s.clients {
+				client.stopReading()
+			}
+		case sub := <-s.subscribe:
+			s.updateTopic(sub)
+		case msg := <-s.broadcast:
+			clients := s.clients
+			if msg.topic != "" {
+				clients = s.topics[msg.topic]
+			}
+			for client := range clients {
+				_ = client.enqueue(msg.data, false, nil)
+			}
 		}
 	}
 }
 
-// DefaultErrorEncoder writes the error to the ResponseWriter,
-// as a json-rpc error response, with an InternalError status code.
+// DefaultErrorEncoder encodes the error as a json-rpc error response, with
+// an InternalError code, for the request ID found in ctx.
 // The Error() string of the error will be used as the response error message.
 // If the error implements ErrorCoder, the provided code will be set on the
 // response error.
-// If the error implements Headerer, the given headers will be set.
 func DefaultErrorEncoder(ctx context.Context, err error) Response {
-	e := Error{
-		Code:    InternalError,
-		Message: err.Error(),
-	}
-	if sc, ok := err.(ErrorCoder); ok {
-		e.Code = sc.ErrorCode()
-	}
-
-	if sc, ok := err.(ErrorData); ok {
-		e.Data = sc.ErrorData()
-	}
-
-	var requestID *RequestID
-	if v := ctx.Value(RequestIDKey); v != nil {
-		requestID = v.(*RequestID)
-	}
-
-	return Response{
-		ID:      requestID,
-		JSONRPC: Version,
-		Error:   &e,
-	}
-}
-
-// ErrorCoder is checked by DefaultErrorEncoder. If an error value implements
-// ErrorCoder, the integer result of ErrorCode() will be used as the JSONRPC
-// error code when encoding the error.
-//
-// By default, InternalError (-32603) is used.
-type ErrorCoder interface {
-	ErrorCode() int
-}
-
-// ErrorData is checked by DefaultErrorEncoder. If an error value implements
-// ErrorData, the interface{} result of ErrorData() will be used as the JSONRPC
-// error data when encoding the error.
-//
-// By default, empty is used.
-type ErrorData interface {
-	ErrorData() interface{}
+	return rpc.DefaultErrorEncoder(ctx, err)
 }
 
 // interceptingWriter intercepts calls to WriteHeader, so that a finalizer
@@ -468,13 +727,8 @@ func (w *interceptingWriter) WriteHeader(code int) {
 	w.ResponseWriter.WriteHeader(code)
 }
 
+// reqID2Str returns a key for the request ID that is equal for equal IDs.
+// String IDs are quoted, so that "1" and 1 yield different keys.
 func reqID2Str(id *RequestID) string {
-	if s, err := id.String(); err == nil {
-		return s
-	}
-	if i, err := id.Int(); err != nil {
-		return strconv.Itoa(i)
-	}
-	f, _ := id.Float32()
-	return strconv.FormatFloat(float64(f), 'f', 2, 32)
+	return id.Key()
 }
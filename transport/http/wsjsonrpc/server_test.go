@@ -3,10 +3,13 @@ package wsjsonrpc_test
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -530,3 +533,377 @@ func TestServer(t *testing.T) {
 //	}()
 //	return func() { stepch <- true }, response
 //}
+
+func TestServerShutdown(t *testing.T) {
+	var (
+		started  = make(chan struct{})
+		finish   = make(chan struct{})
+		streamed = make(chan struct{})
+		ecm      = wsjsonrpc.EndpointCodecMap{
+			"slow": wsjsonrpc.EndpointCodec{
+				Endpoint: func(context.Context, interface{}) (interface{}, error) {
+					close(started)
+					<-finish
+					return "done", nil
+				},
+				Decode: func(context.Context, json.RawMessage) (interface{}, error) { return struct{}{}, nil },
+				Encode: func(_ context.Context, res interface{}) (json.RawMessage, error) { return json.Marshal(res) },
+			},
+		}
+		ecms = wsjsonrpc.EndpointCodecStreamMap{
+			"sub": wsjsonrpc.EndpointCodecStream{
+				Endpoint: func(ctx context.Context, request interface{}) (interface{}, error) {
+					<-finish
+					_ = request.(*wsjsonrpc.Stream).Write("last")
+					close(streamed)
+					return nil, nil
+				},
+				Decode: func(_ context.Context, _ json.RawMessage, stream *wsjsonrpc.Stream) (interface{}, error) {
+					return stream, nil
+				},
+			},
+		}
+		handler = wsjsonrpc.NewServer(ecm, ecms)
+	)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	client := wsjsonrpc.NewClient(mustParse("ws"+strings.TrimPrefix(server.URL, "http")), "sub")
+	defer client.Close()
+	stream, err := client.Stream(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ws := dialTestServer(t, server)
+	defer ws.Close()
+	if err := ws.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc": "2.0", "id": 1, "method": "slow"}`)); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+
+	shutdown := make(chan error)
+	go func() { shutdown <- handler.Shutdown(context.Background()) }()
+
+	// New connections are refused while shutting down.
+	time.Sleep(10 * time.Millisecond)
+	if _, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil); err == nil {
+		t.Fatal("Expected dial to fail during shutdown.")
+	}
+
+	select {
+	case err := <-shutdown:
+		t.Fatalf("Shutdown returned before in-flight work drained: %v", err)
+	default:
+	}
+	close(finish)
+
+	// The in-flight request is answered before the close frame.
+	if want, have := "done", readFrames(t, ws)[0]["result"]; want != have {
+		t.Fatalf("want=%v, have=%v", want, have)
+	}
+	if _, _, err := ws.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Fatalf("want going away close error, have %v", err)
+	}
+
+	// The stream is drained before its connection is closed.
+	<-streamed
+	if have, err := stream.Recv(); err != nil || have != "last" {
+		t.Fatalf("want=last, have=%v (%v)", have, err)
+	}
+
+	select {
+	case err := <-shutdown:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for shutdown")
+	}
+	if err := handler.Broadcast("hello", nil); err != wsjsonrpc.ErrServerClosed {
+		t.Fatalf("want=%v, have=%v", wsjsonrpc.ErrServerClosed, err)
+	}
+}
+
+func TestServerShutdownTimeout(t *testing.T) {
+	cancelled := make(chan struct{})
+	ecms := wsjsonrpc.EndpointCodecStreamMap{
+		"sub": wsjsonrpc.EndpointCodecStream{
+			Endpoint: func(ctx context.Context, request interface{}) (interface{}, error) {
+				<-ctx.Done()
+				close(cancelled)
+				return nil, nil
+			},
+			Decode: func(_ context.Context, _ json.RawMessage, stream *wsjsonrpc.Stream) (interface{}, error) {
+				return stream, nil
+			},
+		},
+	}
+	handler := wsjsonrpc.NewServer(wsjsonrpc.EndpointCodecMap{}, ecms)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	client := wsjsonrpc.NewClient(mustParse("ws"+strings.TrimPrefix(server.URL, "http")), "sub")
+	defer client.Close()
+	if _, err := client.Stream(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := handler.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("want=%v, have=%v", context.DeadlineExceeded, err)
+	}
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for stream context to be cancelled")
+	}
+	if err := handler.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServerOptions(t *testing.T) {
+	ecm := wsjsonrpc.EndpointCodecMap{
+		"echo": wsjsonrpc.EndpointCodec{
+			Endpoint: func(_ context.Context, request interface{}) (interface{}, error) { return request, nil },
+			Decode: func(_ context.Context, msg json.RawMessage) (interface{}, error) {
+				var s string
+				err := json.Unmarshal(msg, &s)
+				return s, err
+			},
+			Encode: func(_ context.Context, res interface{}) (json.RawMessage, error) { return json.Marshal(res) },
+		},
+	}
+	handler := wsjsonrpc.NewServer(
+		ecm,
+		wsjsonrpc.EndpointCodecStreamMap{},
+		wsjsonrpc.ServerMaxMessageSize(1<<16),
+		wsjsonrpc.ServerSendBufferSize(8),
+		wsjsonrpc.ServerBufferSizes(4096, 4096),
+		wsjsonrpc.ServerWriteWait(time.Second),
+		wsjsonrpc.ServerPongWait(time.Second),
+		wsjsonrpc.ServerSubprotocols("jsonrpc-2.0"),
+		wsjsonrpc.ServerEnableCompression(true),
+		wsjsonrpc.ServerCheckOrigin(func(r *http.Request) bool {
+			return r.Header.Get("Origin") != "http://evil.example"
+		}),
+	)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	u := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	if _, _, err := websocket.DefaultDialer.Dial(u, http.Header{"Origin": {"http://evil.example"}}); err == nil {
+		t.Fatal("Expected origin to be rejected.")
+	}
+
+	dialer := &websocket.Dialer{Subprotocols: []string{"jsonrpc-2.0"}, EnableCompression: true}
+	ws, _, err := dialer.Dial(u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	if want, have := "jsonrpc-2.0", ws.Subprotocol(); want != have {
+		t.Fatalf("want=%s, have=%s", want, have)
+	}
+
+	// A message larger than the default limit of 512 bytes is served.
+	large := strings.Repeat("x", 2048)
+	if err := ws.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc": "2.0", "id": 1, "method": "echo", "params": "`+large+`"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := large, readFrames(t, ws)[0]["result"]; want != have {
+		t.Fatalf("want %d bytes, have %v", len(want), have)
+	}
+}
+
+func TestServerBatchWithErrors(t *testing.T) {
+	var (
+		active, maxActive int32
+		ecm               = wsjsonrpc.EndpointCodecMap{
+			"div": wsjsonrpc.EndpointCodec{
+				Endpoint: func(_ context.Context, request interface{}) (interface{}, error) {
+					n := atomic.AddInt32(&active, 1)
+					defer atomic.AddInt32(&active, -1)
+					for {
+						m := atomic.LoadInt32(&maxActive)
+						if n <= m || atomic.CompareAndSwapInt32(&maxActive, m, n) {
+							break
+						}
+					}
+					time.Sleep(10 * time.Millisecond)
+					d := request.(int)
+					if d == 0 {
+						return nil, errors.New("division by zero")
+					}
+					return 12 / d, nil
+				},
+				Decode: func(_ context.Context, msg json.RawMessage) (interface{}, error) {
+					var d int
+					err := json.Unmarshal(msg, &d)
+					return d, err
+				},
+				Encode: func(_ context.Context, res interface{}) (json.RawMessage, error) { return json.Marshal(res) },
+			},
+		}
+		handler = wsjsonrpc.NewServer(ecm, wsjsonrpc.EndpointCodecStreamMap{}, wsjsonrpc.Workers(2))
+	)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	ws := dialTestServer(t, server)
+	defer ws.Close()
+
+	batch := `[
+		{"jsonrpc": "2.0", "id": 1, "method": "div", "params": 0},
+		{"jsonrpc": "2.0", "id": 2, "method": "div", "params": 3},
+		{"jsonrpc": "2.0", "id": 3, "method": "div", "params": "x"},
+		{"jsonrpc": "2.0", "id": 4, "method": "div", "params": 0},
+		{"jsonrpc": "2.0", "id": 5, "method": "div", "params": 4}
+	]`
+	if err := ws.WriteMessage(websocket.TextMessage, []byte(batch)); err != nil {
+		t.Fatal(err)
+	}
+	_ = ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, message, err := ws.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	responses, err := unmarshalResponses(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 5, len(responses); want != have {
+		t.Fatalf("want %d responses, have %d", want, have)
+	}
+	for i, res := range responses {
+		id, _ := res.ID.Int()
+		if want, have := i+1, id; want != have {
+			t.Fatalf("response %d: want id %d, have %d", i, want, have)
+		}
+		if wantErr, haveErr := id == 1 || id == 3 || id == 4, res.Error != nil; wantErr != haveErr {
+			t.Fatalf("response %d: want error %v, have %v", i, wantErr, res.Error)
+		}
+	}
+	if max := atomic.LoadInt32(&maxActive); max > 2 {
+		t.Fatalf("want at most 2 concurrent requests, have %d", max)
+	}
+}
+
+func TestServerNotification(t *testing.T) {
+	var (
+		calls int32
+		ecm   = wsjsonrpc.EndpointCodecMap{
+			"inc": wsjsonrpc.EndpointCodec{
+				Endpoint: func(context.Context, interface{}) (interface{}, error) {
+					return atomic.AddInt32(&calls, 1), nil
+				},
+				Decode: func(context.Context, json.RawMessage) (interface{}, error) { return nil, nil },
+				Encode: func(_ context.Context, res interface{}) (json.RawMessage, error) { return json.Marshal(res) },
+			},
+		}
+		handler = wsjsonrpc.NewServer(ecm, wsjsonrpc.EndpointCodecStreamMap{})
+	)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	ws := dialTestServer(t, server)
+	defer ws.Close()
+
+	for _, message := range []string{
+		`{"jsonrpc": "2.0", "method": "inc"}`,
+		`[{"jsonrpc": "2.0", "method": "inc"}, {"jsonrpc": "2.0", "method": "nope"}]`,
+		`[{"jsonrpc": "2.0", "method": "inc"}, {"jsonrpc": "2.0", "method": "inc", "id": 1}]`,
+	} {
+		if err := ws.WriteMessage(websocket.TextMessage, []byte(message)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, message, err := ws.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	responses, err := unmarshalResponses(message)
+	if err != nil {
+		t.Fatalf("Can't decode response. err=%s, body=%s", err, message)
+	}
+	if len(responses) != 1 {
+		t.Fatalf("want only the response to the request, have %s", message)
+	}
+	if id, _ := responses[0].ID.Int(); id != 1 {
+		t.Fatalf("want id 1, have %s", message)
+	}
+	if want, have := int32(4), atomic.LoadInt32(&calls); want != have {
+		t.Fatalf("endpoint calls: want %d, have %d", want, have)
+	}
+}
+
+func TestServerStrict(t *testing.T) {
+	handler := wsjsonrpc.NewServer(wsjsonrpc.EndpointCodecMap{}, wsjsonrpc.EndpointCodecStreamMap{}, wsjsonrpc.ServerStrict(true))
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	ws := dialTestServer(t, server)
+	defer ws.Close()
+
+	read := func(in string) []byte {
+		t.Helper()
+		if err := ws.WriteMessage(websocket.TextMessage, []byte(in)); err != nil {
+			t.Fatal(err)
+		}
+		_ = ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, message, err := ws.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return message
+	}
+
+	expectErrorCode(t, wsjsonrpc.InvalidRequestError, read(`{"method": "nope", "id": 1}`))
+	expectErrorCode(t, wsjsonrpc.InvalidRequestError, read(`[]`))
+	expectErrorCode(t, wsjsonrpc.ParseError, read(`{"jsonrpc": "2.0",`))
+
+	message := read(`[{"jsonrpc": "2.0", "method": "nope", "params": true}, {"jsonrpc": "2.0", "method": "nope", "id": 2}]`)
+	res, err := unmarshalResponses(message)
+	if err != nil {
+		t.Fatalf("Can't decode response. err=%s, body=%s", err, message)
+	}
+	if len(res) != 2 || res[0].Error == nil || res[0].Error.Code != wsjsonrpc.InvalidRequestError {
+		t.Fatalf("want an invalid request error first, have %s", message)
+	}
+	if res[1].Error == nil || res[1].Error.Code != wsjsonrpc.MethodNotFoundError {
+		t.Fatalf("want a method not found error second, have %s", message)
+	}
+}
+
+func TestServerRequestID(t *testing.T) {
+	ecm := wsjsonrpc.EndpointCodecMap{
+		"nop": wsjsonrpc.EndpointCodec{
+			Endpoint: func(context.Context, interface{}) (interface{}, error) { return struct{}{}, nil },
+			Decode:   func(context.Context, json.RawMessage) (interface{}, error) { return nil, nil },
+			Encode:   nopEncoder,
+		},
+	}
+	server := httptest.NewServer(wsjsonrpc.NewServer(ecm, wsjsonrpc.EndpointCodecStreamMap{}))
+	defer server.Close()
+
+	ws := dialTestServer(t, server)
+	defer ws.Close()
+
+	for _, id := range []string{`null`, `9007199254740993`, `18446744073709551615`, `"A"`, `1.50`} {
+		if err := ws.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc": "2.0", "method": "nop", "id": `+id+`}`)); err != nil {
+			t.Fatal(err)
+		}
+		_ = ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, message, err := ws.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := `"id":` + id; !strings.Contains(string(message), want) {
+			t.Errorf("%s: want %s in response, have %s", id, want, message)
+		}
+	}
+}
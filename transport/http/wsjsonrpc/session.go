@@ -0,0 +1,89 @@
+package wsjsonrpc
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+type connIDKeyType struct{}
+
+type sessionKeyType struct{}
+
+// ConnIDKey is the context key of the ID of the WebSocket connection a
+// request was received on. The ID is a uint64, unique within the process.
+var ConnIDKey connIDKeyType
+
+var sessionKey sessionKeyType
+
+// lastConnID is the ID of the last accepted connection.
+var lastConnID uint64
+
+// ConnectFunc is called once a WebSocket connection has been upgraded, before
+// any message is read from it. If it returns an error, the connection is
+// closed with a policy violation close frame carrying the error message, and
+// no DisconnectFunc is called.
+type ConnectFunc func(ctx context.Context, session *Session) error
+
+// DisconnectFunc is called once a connection accepted by the ConnectFuncs is
+// closed and its streams have ended. err is the error that closed the
+// connection, or nil if the peer or the server closed it normally.
+type DisconnectFunc func(ctx context.Context, session *Session, err error)
+
+// Session is the state kept by the server for a single WebSocket connection.
+// Endpoints use it to remember, for instance, the authenticated user or the
+// subscriptions of the connection across messages. A Session is safe for
+// concurrent use.
+type Session struct {
+	id     uint64
+	mux    sync.RWMutex
+	values map[interface{}]interface{}
+}
+
+func newSession() *Session {
+	return &Session{
+		id:     atomic.AddUint64(&lastConnID, 1),
+		values: map[interface{}]interface{}{},
+	}
+}
+
+// ID returns the ID of the connection, the same as the ConnIDKey value.
+func (s *Session) ID() uint64 {
+	return s.id
+}
+
+// Get returns the value stored for key, and whether there was one.
+func (s *Session) Get(key interface{}) (interface{}, bool) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	v, ok := s.values[key]
+	return v, ok
+}
+
+// Set stores value for key, replacing any previous value.
+func (s *Session) Set(key, value interface{}) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.values[key] = value
+}
+
+// Delete removes the value stored for key.
+func (s *Session) Delete(key interface{}) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	delete(s.values, key)
+}
+
+// SessionFromContext returns the session of the connection a request was
+// received on, or nil if ctx does not come from a Server.
+func SessionFromContext(ctx context.Context) *Session {
+	s, _ := ctx.Value(sessionKey).(*Session)
+	return s
+}
+
+// ConnIDFromContext returns the ID of the connection a request was received
+// on, and whether ctx carries one.
+func ConnIDFromContext(ctx context.Context) (uint64, bool) {
+	id, ok := ctx.Value(ConnIDKey).(uint64)
+	return id, ok
+}
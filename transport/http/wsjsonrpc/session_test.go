@@ -0,0 +1,115 @@
+package wsjsonrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/l-vitaly/go-kit/transport/http/wsjsonrpc"
+)
+
+func TestServerSession(t *testing.T) {
+	type userKey struct{}
+
+	var (
+		connected    = make(chan uint64, 1)
+		disconnected = make(chan uint64, 1)
+		ecm          = wsjsonrpc.EndpointCodecMap{
+			"login": wsjsonrpc.EndpointCodec{
+				Endpoint: func(ctx context.Context, request interface{}) (interface{}, error) {
+					wsjsonrpc.SessionFromContext(ctx).Set(userKey{}, request)
+					return true, nil
+				},
+				Decode: func(_ context.Context, msg json.RawMessage) (interface{}, error) {
+					var name string
+					err := json.Unmarshal(msg, &name)
+					return name, err
+				},
+				Encode: func(_ context.Context, res interface{}) (json.RawMessage, error) { return json.Marshal(res) },
+			},
+			"whoami": wsjsonrpc.EndpointCodec{
+				Endpoint: func(ctx context.Context, _ interface{}) (interface{}, error) {
+					id, _ := wsjsonrpc.ConnIDFromContext(ctx)
+					user, _ := wsjsonrpc.SessionFromContext(ctx).Get(userKey{})
+					return []interface{}{id, user}, nil
+				},
+				Decode: func(context.Context, json.RawMessage) (interface{}, error) { return nil, nil },
+				Encode: func(_ context.Context, res interface{}) (json.RawMessage, error) { return json.Marshal(res) },
+			},
+		}
+		handler = wsjsonrpc.NewServer(
+			ecm,
+			wsjsonrpc.EndpointCodecStreamMap{},
+			wsjsonrpc.ServerOnConnect(func(ctx context.Context, session *wsjsonrpc.Session) error {
+				if id, _ := wsjsonrpc.ConnIDFromContext(ctx); id != session.ID() {
+					t.Errorf("want=%d, have=%d", session.ID(), id)
+				}
+				connected <- session.ID()
+				return nil
+			}),
+			wsjsonrpc.ServerOnDisconnect(func(_ context.Context, session *wsjsonrpc.Session, err error) {
+				if err != nil {
+					t.Errorf("want no error, have %v", err)
+				}
+				disconnected <- session.ID()
+			}),
+		)
+	)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	ws := dialTestServer(t, server)
+	id := <-connected
+
+	if err := ws.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","method":"login","params":"gopher","id":1}`)); err != nil {
+		t.Fatal(err)
+	}
+	readFrames(t, ws)
+	if err := ws.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","method":"whoami","id":2}`)); err != nil {
+		t.Fatal(err)
+	}
+	frames := readFrames(t, ws)
+	result, _ := frames[0]["result"].([]interface{})
+	if len(result) != 2 || result[0] != float64(id) || result[1] != "gopher" {
+		t.Fatalf("want [%d gopher], have %v", id, frames[0]["result"])
+	}
+
+	_ = ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	_ = ws.Close()
+	select {
+	case have := <-disconnected:
+		if have != id {
+			t.Fatalf("want=%d, have=%d", id, have)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnDisconnect not called")
+	}
+}
+
+func TestServerOnConnectReject(t *testing.T) {
+	handler := wsjsonrpc.NewServer(
+		wsjsonrpc.EndpointCodecMap{},
+		wsjsonrpc.EndpointCodecStreamMap{},
+		wsjsonrpc.ServerOnConnect(func(context.Context, *wsjsonrpc.Session) error {
+			return errors.New("unauthorized")
+		}),
+	)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	ws := dialTestServer(t, server)
+	defer ws.Close()
+
+	_, _, err := ws.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.ClosePolicyViolation) {
+		t.Fatalf("want policy violation close error, have %v", err)
+	}
+	if e := err.(*websocket.CloseError); e.Text != "unauthorized" {
+		t.Fatalf("want=unauthorized, have=%s", e.Text)
+	}
+}
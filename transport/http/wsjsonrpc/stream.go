@@ -0,0 +1,173 @@
+package wsjsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+)
+
+// UnsubscribeMethod is the method a client sends, with the request ID of an
+// open stream, to end that stream. The server answers it with the terminal
+// frame of the stream, or with an InvalidParamsError if no stream with that
+// ID is open.
+const UnsubscribeMethod = "rpc.unsubscribe"
+
+// terminalResult is the result of the frame which ends a stream successfully.
+var terminalResult = json.RawMessage("true")
+
+// ErrStreamClosed is returned by Stream.Write once the stream has ended.
+var ErrStreamClosed = errors.New("wsjsonrpc: stream closed")
+
+// Stream is the server side of a subscription to a method served through an
+// EndpointCodecStreamMap. Every frame written to the stream carries the ID of
+// the request that opened it and has Response.Stream set. The stream ends
+// when its endpoint returns, when Close or CloseWithError is called, when the
+// client unsubscribes, or when the connection drops. The frame that ends the
+// stream has Response.Stream unset, and a true result unless it carries the
+// error which ended the stream.
+type Stream struct {
+	ctx        context.Context
+	cancel     context.CancelFunc
+	streamRead chan []byte
+	reqID      *RequestID
+	c          *wsClient
+
+	mux       sync.RWMutex
+	closed    bool
+	done      chan struct{}
+	closeOnce sync.Once
+
+	// ready is closed once the acknowledgement of the stream request has
+	// been queued, so that no frame overtakes it.
+	ready     chan struct{}
+	readyOnce sync.Once
+}
+
+func newStream(ctx context.Context, c *wsClient, reqID *RequestID) *Stream {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Stream{
+		ctx:        ctx,
+		cancel:     cancel,
+		streamRead: make(chan []byte),
+		reqID:      reqID,
+		c:          c,
+		done:       make(chan struct{}),
+		ready:      make(chan struct{}),
+	}
+}
+
+// Read returns the channel of follow-up params sent by the client. The
+// channel is closed when the stream ends.
+func (s *Stream) Read() chan []byte {
+	return s.streamRead
+}
+
+// Context returns the context of the stream, which is cancelled when the
+// stream ends.
+func (s *Stream) Context() context.Context {
+	return s.ctx
+}
+
+// Done returns a channel that is closed when the stream ends.
+func (s *Stream) Done() <-chan struct{} {
+	return s.done
+}
+
+// Write sends v as the result of a stream frame. It returns ErrStreamClosed
+// if the stream has ended, and ErrSendBufferFull if the frame was dropped
+// because the client does not keep up (see ServerSendPolicy).
+func (s *Stream) Write(v interface{}) error {
+	result, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.write(Response{
+		JSONRPC: Version,
+		Result:  result,
+		ID:      s.reqID,
+		Stream:  true,
+	})
+}
+
+// Close ends the stream and sends the terminal frame to the client.
+func (s *Stream) Close() error {
+	if !s.end(&Response{JSONRPC: Version, Result: terminalResult, ID: s.reqID}) {
+		return ErrStreamClosed
+	}
+	return nil
+}
+
+// CloseWithError ends the stream and sends err, encoded with the server's
+// ErrorEncoder, as the terminal frame to the client.
+func (s *Stream) CloseWithError(err error) error {
+	res := s.c.s.errorEncoder(s.ctx, err)
+	res.Stream = false
+	if !s.end(&res) {
+		return ErrStreamClosed
+	}
+	return nil
+}
+
+func (s *Stream) write(res Response) error {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	if s.closed {
+		return ErrStreamClosed
+	}
+	select {
+	case <-s.ready:
+	case <-s.done:
+		return ErrStreamClosed
+	case <-s.c.done:
+		return ErrStreamClosed
+	}
+	err := s.c.enqueue(s.c.s.marshalResponse([]Response{res}, false), true, s.done)
+	if err == errSendCancelled {
+		return ErrStreamClosed
+	}
+	return err
+}
+
+func (s *Stream) markReady() {
+	s.readyOnce.Do(func() { close(s.ready) })
+}
+
+// deliver hands follow-up params to the endpoint reading the stream.
+func (s *Stream) deliver(params []byte) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.streamRead <- params:
+	case <-s.done:
+	}
+}
+
+// end ends the stream, writing the terminal frame first if one is given. It
+// reports whether the stream was still open.
+func (s *Stream) end(terminal *Response) bool {
+	ended := false
+	s.closeOnce.Do(func() {
+		ended = true
+		if terminal != nil {
+			_ = s.write(*terminal)
+		}
+		close(s.done)
+
+		s.mux.Lock()
+		s.closed = true
+		close(s.streamRead)
+		s.mux.Unlock()
+
+		s.c.streamMux.Lock()
+		delete(s.c.stream, reqID2Str(s.reqID))
+		s.c.streamMux.Unlock()
+
+		s.cancel()
+		s.c.streamWG.Done()
+	})
+	return ended
+}
@@ -0,0 +1,192 @@
+package wsjsonrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/l-vitaly/go-kit/transport/http/wsjsonrpc"
+)
+
+func testStreamServer(endpoint func(context.Context, *wsjsonrpc.Stream) error) (*httptest.Server, *wsjsonrpc.Client) {
+	ecms := wsjsonrpc.EndpointCodecStreamMap{
+		"sub": wsjsonrpc.EndpointCodecStream{
+			Endpoint: func(ctx context.Context, request interface{}) (interface{}, error) {
+				return nil, endpoint(ctx, request.(*wsjsonrpc.Stream))
+			},
+			Decode: func(_ context.Context, _ json.RawMessage, stream *wsjsonrpc.Stream) (interface{}, error) {
+				return stream, nil
+			},
+		},
+	}
+	server := httptest.NewServer(wsjsonrpc.NewServer(wsjsonrpc.EndpointCodecMap{}, ecms))
+	client := wsjsonrpc.NewClient(mustParse("ws"+strings.TrimPrefix(server.URL, "http")), "sub")
+	return server, client
+}
+
+func TestStreamCompletion(t *testing.T) {
+	server, client := testStreamServer(func(_ context.Context, stream *wsjsonrpc.Stream) error {
+		_ = stream.Write(1)
+		_ = stream.Write(2)
+		return nil
+	})
+	defer server.Close()
+	defer client.Close()
+
+	stream, err := client.Stream(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []float64{1, 2} {
+		have, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want != have {
+			t.Fatalf("want=%v, have=%v", want, have)
+		}
+	}
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Fatalf("want=%v, have=%v", io.EOF, err)
+	}
+}
+
+func TestStreamCloseWithError(t *testing.T) {
+	server, client := testStreamServer(func(_ context.Context, stream *wsjsonrpc.Stream) error {
+		return errors.New("dang")
+	})
+	defer server.Close()
+	defer client.Close()
+
+	stream, err := client.Stream(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = stream.Recv()
+	if e, ok := err.(wsjsonrpc.Error); !ok || e.Message != "dang" {
+		t.Fatalf("want dang error, have %v", err)
+	}
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Fatalf("want=%v, have=%v", io.EOF, err)
+	}
+}
+
+func TestStreamUnsubscribe(t *testing.T) {
+	cancelled := make(chan struct{})
+	server, client := testStreamServer(func(ctx context.Context, stream *wsjsonrpc.Stream) error {
+		_ = stream.Write("subscribed")
+		<-ctx.Done()
+		if err := stream.Write("late"); err != wsjsonrpc.ErrStreamClosed {
+			t.Errorf("want=%v, have=%v", wsjsonrpc.ErrStreamClosed, err)
+		}
+		close(cancelled)
+		return nil
+	})
+	defer server.Close()
+	defer client.Close()
+
+	stream, err := client.Stream(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for stream context to be cancelled")
+	}
+}
+
+func TestStreamCancelledOnDisconnect(t *testing.T) {
+	cancelled := make(chan struct{})
+	server, client := testStreamServer(func(ctx context.Context, stream *wsjsonrpc.Stream) error {
+		_ = stream.Write("subscribed")
+		<-ctx.Done()
+		close(cancelled)
+		return nil
+	})
+	defer server.Close()
+
+	stream, err := client.Stream(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatal(err)
+	}
+	_ = client.Close()
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for stream context to be cancelled")
+	}
+}
+
+func TestStreamTerminalFrames(t *testing.T) {
+	ecms := wsjsonrpc.EndpointCodecStreamMap{
+		"wait": wsjsonrpc.EndpointCodecStream{
+			Endpoint: func(ctx context.Context, _ interface{}) (interface{}, error) {
+				<-ctx.Done()
+				return nil, nil
+			},
+			Decode: func(context.Context, json.RawMessage, *wsjsonrpc.Stream) (interface{}, error) { return nil, nil },
+		},
+		"empty": wsjsonrpc.EndpointCodecStream{
+			Endpoint: func(context.Context, interface{}) (interface{}, error) { return nil, nil },
+			Decode:   func(context.Context, json.RawMessage, *wsjsonrpc.Stream) (interface{}, error) { return nil, nil },
+		},
+	}
+	server := httptest.NewServer(wsjsonrpc.NewServer(wsjsonrpc.EndpointCodecMap{}, ecms))
+	defer server.Close()
+	ws := dialTestServer(t, server)
+	defer ws.Close()
+
+	// frames reads the next n frames.
+	frames := func(n int) (frames []map[string]interface{}) {
+		for len(frames) < n {
+			frames = append(frames, readFrames(t, ws)...)
+		}
+		return frames
+	}
+	send := func(msg string) {
+		if err := ws.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	send(`{"jsonrpc": "2.0", "method": "empty", "id": 1}`)
+	fs := frames(2)
+	if ack := fs[0]; ack["stream"] != true || ack["result"] != nil {
+		t.Fatalf("want the acknowledgement first, have %v", fs)
+	}
+	if end := fs[1]; end["stream"] != nil || end["result"] != true || end["error"] != nil {
+		t.Fatalf("want a terminal frame with a true result, have %v", end)
+	}
+
+	send(`{"jsonrpc": "2.0", "method": "wait", "id": 2}`)
+	frames(1)
+	send(`{"jsonrpc": "2.0", "method": "rpc.unsubscribe", "id": 2}`)
+	if end := frames(1)[0]; end["id"] != 2.0 || end["result"] != true || end["error"] != nil {
+		t.Fatalf("want a terminal frame with a true result, have %v", end)
+	}
+
+	send(`{"jsonrpc": "2.0", "method": "rpc.unsubscribe", "id": 2}`)
+	res := frames(1)[0]
+	if e, ok := res["error"].(map[string]interface{}); !ok || e["code"] != float64(wsjsonrpc.InvalidParamsError) {
+		t.Fatalf("want an invalid params error for a stream not open, have %v", res)
+	}
+}
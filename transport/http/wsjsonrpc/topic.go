@@ -0,0 +1,112 @@
+package wsjsonrpc
+
+import (
+	"context"
+	"encoding/json"
+
+	rpc "github.com/l-vitaly/go-kit/transport/jsonrpc"
+)
+
+const (
+	// TopicSubscribeMethod is the method a client calls with params
+	// {"topic": "<name>"} to receive the messages published to a topic.
+	TopicSubscribeMethod = "rpc.topic.subscribe"
+
+	// TopicUnsubscribeMethod is the method a client calls with params
+	// {"topic": "<name>"} to stop receiving the messages of a topic.
+	TopicUnsubscribeMethod = "rpc.topic.unsubscribe"
+)
+
+type topicParams struct {
+	Topic string `json:"topic"`
+}
+
+type topicSubscription struct {
+	topic  string
+	client *wsClient
+	on     bool
+}
+
+type topicMessage struct {
+	topic string
+	data  []byte
+}
+
+// Broadcast sends params to every connected client as a JSON RPC
+// notification of the given method.
+func (s *Server) Broadcast(method string, params interface{}) error {
+	return s.push("", method, params)
+}
+
+// Publish sends params to every client subscribed to topic as a JSON RPC
+// notification whose method is the topic name. Clients subscribe with
+// TopicSubscribeMethod.
+func (s *Server) Publish(topic string, params interface{}) error {
+	return s.push(topic, topic, params)
+}
+
+func (s *Server) push(topic, method string, params interface{}) error {
+	b, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(Notification{
+		JSONRPC: Version,
+		Method:  method,
+		Params:  b,
+	})
+	if err != nil {
+		return err
+	}
+	select {
+	case s.broadcast <- topicMessage{topic: topic, data: data}:
+		return nil
+	case <-s.stopped:
+		return ErrServerClosed
+	}
+}
+
+// topicRequest handles the TopicSubscribeMethod and TopicUnsubscribeMethod
+// requests of a client.
+func (s *Server) topicRequest(ctx context.Context, c *wsClient, req Request) Response {
+	var params topicParams
+	if err := json.Unmarshal(req.Params, &params); err != nil || params.Topic == "" {
+		err := rpc.NewError(InvalidParamsError, "Topic params must be an object with a non-empty topic.")
+		_ = s.logger.Log("err", err)
+		return s.errorEncoder(ctx, err)
+	}
+	select {
+	case s.subscribe <- topicSubscription{
+		topic:  params.Topic,
+		client: c,
+		on:     req.Method == TopicSubscribeMethod,
+	}:
+	case <-s.stopped:
+	}
+	return Response{
+		ID:      req.ID,
+		JSONRPC: Version,
+		Result:  json.RawMessage("true"),
+	}
+}
+
+// updateTopic applies a subscription change. It must only be called by the
+// run goroutine.
+func (s *Server) updateTopic(sub topicSubscription) {
+	if _, ok := s.clients[sub.client]; !ok {
+		return
+	}
+	clients, ok := s.topics[sub.topic]
+	if sub.on {
+		if !ok {
+			clients = make(map[*wsClient]bool)
+			s.topics[sub.topic] = clients
+		}
+		clients[sub.client] = true
+		return
+	}
+	delete(clients, sub.client)
+	if ok && len(clients) == 0 {
+		delete(s.topics, sub.topic)
+	}
+}
@@ -0,0 +1,145 @@
+package wsjsonrpc_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/l-vitaly/go-kit/transport/http/wsjsonrpc"
+)
+
+// readFrames reads the next WebSocket message, which may hold several
+// JSON values.
+func readFrames(t *testing.T, ws *websocket.Conn) (frames []map[string]interface{}) {
+	t.Helper()
+
+	_, message, err := ws.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(message))
+	for {
+		var frame map[string]interface{}
+		if err := dec.Decode(&frame); err == io.EOF {
+			return frames
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		frames = append(frames, frame)
+	}
+}
+
+func dialTestServer(t *testing.T, server *httptest.Server) *websocket.Conn {
+	t.Helper()
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ws
+}
+
+func TestServerPublish(t *testing.T) {
+	handler := wsjsonrpc.NewServer(wsjsonrpc.EndpointCodecMap{}, wsjsonrpc.EndpointCodecStreamMap{})
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	subscriber := dialTestServer(t, server)
+	defer subscriber.Close()
+	other := dialTestServer(t, server)
+	defer other.Close()
+
+	for ws, topic := range map[*websocket.Conn]string{subscriber: "events", other: "other"} {
+		if err := ws.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc": "2.0", "id": 1, "method": "rpc.topic.subscribe", "params": {"topic": "`+topic+`"}}`)); err != nil {
+			t.Fatal(err)
+		}
+		if frames := readFrames(t, ws); frames[0]["result"] != true {
+			t.Fatalf("want subscription result true, have %v", frames[0])
+		}
+	}
+
+	// A ClientConn hands the notifications to its handler.
+	notifications := make(chan wsjsonrpc.Notification, 2)
+	cc, err := wsjsonrpc.Dial(
+		mustParse("ws"+strings.TrimPrefix(server.URL, "http")), nil, nil,
+		wsjsonrpc.ClientConnNotificationHandler(func(n wsjsonrpc.Notification) { notifications <- n }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cc.Close()
+	res, err := cc.Call(context.Background(), wsjsonrpc.TopicSubscribeMethod, json.RawMessage(`{"topic": "events"}`), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+
+	if err := handler.Publish("events", map[string]int{"n": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := handler.Broadcast("hello", "all"); err != nil {
+		t.Fatal(err)
+	}
+
+	var frames []map[string]interface{}
+	for len(frames) < 2 {
+		frames = append(frames, readFrames(t, subscriber)...)
+	}
+	if want, have := "events", frames[0]["method"]; want != have {
+		t.Fatalf("want=%v, have=%v", want, have)
+	}
+	if _, ok := frames[0]["id"]; ok {
+		t.Fatalf("notification must not have an id: %v", frames[0])
+	}
+	if want, have := "hello", frames[1]["method"]; want != have {
+		t.Fatalf("want=%v, have=%v", want, have)
+	}
+
+	// The client that did not subscribe to events only receives the broadcast.
+	if want, have := "hello", readFrames(t, other)[0]["method"]; want != have {
+		t.Fatalf("want=%v, have=%v", want, have)
+	}
+
+	for _, want := range []wsjsonrpc.Notification{
+		{JSONRPC: wsjsonrpc.Version, Method: "events", Params: json.RawMessage(`{"n":1}`)},
+		{JSONRPC: wsjsonrpc.Version, Method: "hello", Params: json.RawMessage(`"all"`)},
+	} {
+		select {
+		case have := <-notifications:
+			if want.JSONRPC != have.JSONRPC || want.Method != have.Method || string(want.Params) != string(have.Params) {
+				t.Fatalf("want=%+v, have=%+v", want, have)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("want notification %s, have none", want.Method)
+		}
+	}
+}
+
+func TestServerTopicBadParams(t *testing.T) {
+	server := httptest.NewServer(wsjsonrpc.NewServer(wsjsonrpc.EndpointCodecMap{}, wsjsonrpc.EndpointCodecStreamMap{}))
+	defer server.Close()
+
+	ws := dialTestServer(t, server)
+	defer ws.Close()
+
+	if err := ws.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc": "2.0", "id": 1, "method": "rpc.topic.subscribe", "params": [1]}`)); err != nil {
+		t.Fatal(err)
+	}
+	frame := readFrames(t, ws)[0]
+	e, ok := frame["error"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("want error, have %v", frame)
+	}
+	if want, have := float64(wsjsonrpc.InvalidParamsError), e["code"]; want != have {
+		t.Fatalf("want=%v, have=%v", want, have)
+	}
+}
@@ -0,0 +1,249 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrNoResponse is set as the error of a BatchCall the server returned no
+// response for, not even an error response with a null ID.
+var ErrNoResponse = errors.New("jsonrpc: no response for the request")
+
+// BatchCall is a single call of a batch.
+type BatchCall struct {
+	// Method is the JSON RPC method called.
+	Method string
+
+	// Request is encoded to the params of the call with Encode, or with
+	// DefaultRequestEncoder if Encode is nil.
+	Request interface{}
+	Encode  EncodeRequestFunc
+
+	// Response is the result of the call, decoded with Decode, or with
+	// DefaultResponseDecoder if Decode is nil. Err is set instead if the
+	// call failed.
+	Decode   DecodeResponseFunc
+	Response interface{}
+	Err      error
+}
+
+// DefaultRequestEncoder marshals the given request to JSON.
+func DefaultRequestEncoder(_ context.Context, req interface{}) (json.RawMessage, error) {
+	return json.Marshal(req)
+}
+
+// DefaultResponseDecoder unmarshals the result to interface{}, or returns an
+// error, if found.
+func DefaultResponseDecoder(_ context.Context, res Response) (interface{}, error) {
+	if res.Error != nil {
+		return nil, *res.Error
+	}
+	var result interface{}
+	if err := json.Unmarshal(res.Result, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Batch is a batch of calls, built by NewBatch. A transport sends its
+// Requests as a single message, and hands the responses of the server back
+// to Resolve.
+type Batch struct {
+	// Requests are the requests of the calls, in order.
+	Requests []Request
+
+	calls []*BatchCall
+	ids   []interface{}
+	byID  map[string]int
+}
+
+// NewBatch encodes the params of calls and identifies each of them with an
+// ID returned by g. The IDs must be unique within the batch.
+func NewBatch(ctx context.Context, g RequestIDGenerator, calls ...*BatchCall) (*Batch, error) {
+	b := &Batch{
+		Requests: make([]Request, len(calls)),
+		calls:    calls,
+		ids:      make([]interface{}, len(calls)),
+		byID:     make(map[string]int, len(calls)),
+	}
+	for i, call := range calls {
+		enc := call.Encode
+		if enc == nil {
+			enc = DefaultRequestEncoder
+		}
+		params, err := enc(ctx, call.Request)
+		if err != nil {
+			return nil, err
+		}
+		id := g.Generate()
+		req, err := NewRequest(call.Method, params, id)
+		if err != nil {
+			return nil, err
+		}
+		b.ids[i], b.byID[req.ID.Key()] = id, i
+		b.Requests[i] = req
+	}
+	return b, nil
+}
+
+// DecodeBatchResponse decodes data, the message the server answered a batch
+// with, to its responses. A single error response means the server rejected
+// the batch as a whole, and is returned as the error.
+func DecodeBatchResponse(data []byte) ([]Response, error) {
+	if !IsBatchMessage(data) {
+		var res Response
+		if err := json.Unmarshal(data, &res); err != nil {
+			return nil, err
+		}
+		if res.Error == nil {
+			return nil, errors.New("jsonrpc: unexpected single response to a batch")
+		}
+		return nil, *res.Error
+	}
+	var responses []Response
+	if err := json.Unmarshal(data, &responses); err != nil {
+		return nil, err
+	}
+	return responses, nil
+}
+
+// Resolve sets the Response or Err of each call of the batch from the
+// responses of the server, matched by ID. The calls left unanswered are set
+// ErrNoResponse.
+func (b *Batch) Resolve(ctx context.Context, responses []Response) {
+	answered := make([]bool, len(b.calls))
+	var unmatched []Response
+	for _, res := range responses {
+		if res.ID == nil || res.ID.IsNull() {
+			if res.Error != nil {
+				unmatched = append(unmatched, res)
+			}
+			continue
+		}
+		i, ok := b.byID[res.ID.Key()]
+		if !ok || answered[i] {
+			continue
+		}
+		answered[i] = true
+		b.resolve(ctx, i, res)
+	}
+	// The server answers each call it could not read the ID of with an error
+	// with a null ID. These go, in order, to the calls left unanswered, one
+	// each.
+	for i, call := range b.calls {
+		if answered[i] {
+			continue
+		}
+		if len(unmatched) == 0 {
+			call.Err = ErrNoResponse
+			continue
+		}
+		b.resolve(ctx, i, unmatched[0])
+		unmatched = unmatched[1:]
+	}
+}
+
+// resolve sets the Response or Err of the i-th call from res.
+func (b *Batch) resolve(ctx context.Context, i int, res Response) {
+	call := b.calls[i]
+	if err := ValidateResponse(b.ids[i], res); err != nil {
+		call.Err = err
+		return
+	}
+	dec := call.Decode
+	if dec == nil {
+		dec = DefaultResponseDecoder
+	}
+	call.Response, call.Err = dec(ctx, res)
+}
+
+// BatchQueue gathers calls into batches. The calls made within window of the
+// first queued one are sent together, or as soon as maxSize calls are
+// queued, if maxSize is positive.
+type BatchQueue struct {
+	send    func(context.Context, ...*BatchCall) error
+	window  time.Duration
+	maxSize int
+
+	mux   sync.Mutex
+	queue []*queuedCall
+	timer *time.Timer
+}
+
+// queuedCall is a call waiting for its batch to be sent.
+type queuedCall struct {
+	call *BatchCall
+	done chan struct{}
+}
+
+// NewBatchQueue returns a BatchQueue which sends its batches with send,
+// typically the Do method of a batch client. The batches are sent with a
+// background context.
+func NewBatchQueue(send func(context.Context, ...*BatchCall) error, window time.Duration, maxSize int) *BatchQueue {
+	return &BatchQueue{
+		send:    send,
+		window:  window,
+		maxSize: maxSize,
+	}
+}
+
+// Call queues call and waits until its batch is sent, which sets its
+// Response or Err, or until ctx is done, in which case ctx.Err() is returned
+// and the call is still sent.
+func (q *BatchQueue) Call(ctx context.Context, call *BatchCall) error {
+	qc := &queuedCall{call: call, done: make(chan struct{})}
+	q.enqueue(qc)
+	select {
+	case <-qc.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (q *BatchQueue) enqueue(qc *queuedCall) {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+	q.queue = append(q.queue, qc)
+	if q.maxSize > 0 && len(q.queue) >= q.maxSize {
+		if q.timer != nil {
+			q.timer.Stop()
+			q.timer = nil
+		}
+		go q.sendQueue(q.queue)
+		q.queue = nil
+		return
+	}
+	if q.timer == nil {
+		q.timer = time.AfterFunc(q.window, q.flush)
+	}
+}
+
+// flush sends the queued calls.
+func (q *BatchQueue) flush() {
+	q.mux.Lock()
+	queue := q.queue
+	q.queue, q.timer = nil, nil
+	q.mux.Unlock()
+	if len(queue) > 0 {
+		q.sendQueue(queue)
+	}
+}
+
+func (q *BatchQueue) sendQueue(queue []*queuedCall) {
+	calls := make([]*BatchCall, len(queue))
+	for i, qc := range queue {
+		calls[i] = qc.call
+	}
+	if err := q.send(context.Background(), calls...); err != nil {
+		for _, call := range calls {
+			call.Err = err
+		}
+	}
+	for _, qc := range queue {
+		close(qc.done)
+	}
+}
@@ -0,0 +1,103 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/l-vitaly/go-kit/transport/jsonrpc"
+)
+
+func TestBatch(t *testing.T) {
+	calls := []*jsonrpc.BatchCall{
+		{Method: "upper", Request: "a"},
+		{Method: "upper", Request: "b"},
+		{Method: "upper", Request: "c"},
+		{Method: "upper", Request: "d"},
+	}
+	batch, err := jsonrpc.NewBatch(context.Background(), jsonrpc.NewAutoIncrementID(0), calls...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, req := range batch.Requests {
+		if want, have := jsonrpc.Version, req.JSONRPC; want != have {
+			t.Fatalf("want %q, have %q", want, have)
+		}
+		if want, have := `"`+calls[i].Request.(string)+`"`, string(req.Params); want != have {
+			t.Fatalf("want %s, have %s", want, have)
+		}
+	}
+
+	responses, err := jsonrpc.DecodeBatchResponse([]byte(`[
+		{"jsonrpc":"2.0","result":"B","id":1},
+		{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null},
+		{"jsonrpc":"2.0","result":"A","id":0},
+		{"jsonrpc":"2.0","result":"X","id":7}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch.Resolve(context.Background(), responses)
+
+	if calls[0].Err != nil || calls[0].Response != "A" {
+		t.Fatalf("want A, have %v (%v)", calls[0].Response, calls[0].Err)
+	}
+	if calls[1].Err != nil || calls[1].Response != "B" {
+		t.Fatalf("want B, have %v (%v)", calls[1].Response, calls[1].Err)
+	}
+	if e, ok := calls[2].Err.(jsonrpc.Error); !ok || e.Code != jsonrpc.InvalidRequestError {
+		t.Fatalf("want the null ID error, have %v (%v)", calls[2].Response, calls[2].Err)
+	}
+	if want, have := jsonrpc.ErrNoResponse, calls[3].Err; want != have {
+		t.Fatalf("want %v, have %v (%v)", want, calls[3].Response, have)
+	}
+}
+
+func TestDecodeBatchResponseRejected(t *testing.T) {
+	_, err := jsonrpc.DecodeBatchResponse([]byte(`{"jsonrpc":"2.0","error":{"code":-32700,"message":"Parse error"},"id":null}`))
+	if e, ok := err.(jsonrpc.Error); !ok || e.Code != jsonrpc.ParseError {
+		t.Fatalf("want a parse error, have %v", err)
+	}
+	if _, err = jsonrpc.DecodeBatchResponse([]byte(`{"jsonrpc":"2.0","result":1,"id":0}`)); err == nil {
+		t.Fatal("want an error for a single result, have none")
+	}
+}
+
+func TestBatchQueue(t *testing.T) {
+	var (
+		mux     sync.Mutex
+		batches []int
+	)
+	send := func(_ context.Context, calls ...*jsonrpc.BatchCall) error {
+		mux.Lock()
+		batches = append(batches, len(calls))
+		mux.Unlock()
+		for _, call := range calls {
+			call.Response = call.Request
+		}
+		return nil
+	}
+	q := jsonrpc.NewBatchQueue(send, time.Hour, 3)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			call := &jsonrpc.BatchCall{Method: "echo", Request: i}
+			if err := q.Call(context.Background(), call); err != nil {
+				t.Error(err)
+				return
+			}
+			if want, have := i, call.Response; want != have {
+				t.Errorf("want %v, have %v", want, have)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if want, have := []int{3}, batches; len(have) != 1 || have[0] != want[0] {
+		t.Fatalf("want batches %v, have %v", want, have)
+	}
+}
@@ -0,0 +1,91 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+)
+
+// RequestIDGenerator returns an ID for the request.
+type RequestIDGenerator interface {
+	Generate() interface{}
+}
+
+// autoIncrementID is a RequestIDGenerator that generates
+// auto-incrementing integer IDs.
+type autoIncrementID struct {
+	v *uint64
+}
+
+// NewAutoIncrementID returns an auto-incrementing request ID generator,
+// initialised with the given value.
+func NewAutoIncrementID(init uint64) RequestIDGenerator {
+	// Offset by one so that the first generated value = init.
+	v := init - 1
+	return &autoIncrementID{v: &v}
+}
+
+// Generate satisfies RequestIDGenerator
+func (i *autoIncrementID) Generate() interface{} {
+	id := atomic.AddUint64(i.v, 1)
+	return id
+}
+
+// NewRequest returns a request for method with the given params, identified
+// by id, a value returned by a RequestIDGenerator.
+func NewRequest(method string, params json.RawMessage, id interface{}) (Request, error) {
+	rid, err := NewRequestID(id)
+	if err != nil {
+		return Request{}, err
+	}
+	return Request{
+		JSONRPC: Version,
+		Method:  method,
+		Params:  params,
+		ID:      rid,
+	}, nil
+}
+
+// InvalidResponseError is returned by client endpoints when the server's
+// response is not a valid JSON RPC response object: its body could not be
+// decoded, or its jsonrpc member is not "2.0".
+type InvalidResponseError struct {
+	Reason string
+}
+
+func (e InvalidResponseError) Error() string {
+	return "jsonrpc: invalid response: " + e.Reason
+}
+
+// ResponseIDMismatchError is returned by client endpoints when the ID of the
+// server's response is not the ID of the request. Both IDs are given as raw
+// JSON.
+type ResponseIDMismatchError struct {
+	Want json.RawMessage
+	Have json.RawMessage
+}
+
+func (e ResponseIDMismatchError) Error() string {
+	return fmt.Sprintf("jsonrpc: response id %s does not match request id %s", e.Have, e.Want)
+}
+
+// ValidateResponse checks that res is a JSON RPC 2.0 response to the request
+// with the given id. An error response with a null ID, which the server
+// sends when it could not read the request ID, is accepted.
+func ValidateResponse(id interface{}, res Response) error {
+	if res.JSONRPC != Version {
+		return InvalidResponseError{Reason: fmt.Sprintf("jsonrpc member is %q, want %q", res.JSONRPC, Version)}
+	}
+	want, err := NewRequestID(id)
+	if err != nil {
+		return err
+	}
+	if (res.ID == nil || res.ID.IsNull()) && res.Error != nil {
+		return nil
+	}
+	if !want.Equal(res.ID) {
+		have, _ := res.ID.MarshalJSON()
+		return ResponseIDMismatchError{Want: want.raw, Have: have}
+	}
+	return nil
+}
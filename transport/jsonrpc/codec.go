@@ -0,0 +1,19 @@
+package jsonrpc
+
+import "encoding/json"
+
+// Codec marshals and unmarshals the JSON RPC messages. It lets transports
+// use a faster JSON implementation than the standard library.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// StdCodec is the Codec of the encoding/json package.
+var StdCodec Codec = stdCodec{}
+
+type stdCodec struct{}
+
+func (stdCodec) Marshal(v interface{}) ([]byte, error) { return json.Marshal(v) }
+
+func (stdCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
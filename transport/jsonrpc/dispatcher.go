@@ -0,0 +1,272 @@
+package jsonrpc
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-kit/kit/log"
+)
+
+// Handler answers a single request of a message. The ID of the request is
+// found in ctx under RequestIDKey.
+type Handler func(ctx context.Context, req Request) Response
+
+// EndpointHandler returns a Handler which answers requests with the endpoints
+// of ecm. Requests for a method missing from ecm are answered with a
+// MethodNotFoundError. Errors are logged to logger, and encoded with ee.
+func EndpointHandler(ecm EndpointCodecMap, ee ErrorEncoder, logger log.Logger) Handler {
+	return func(ctx context.Context, req Request) Response {
+		// Get the endpoint and codecs from the map using the method
+		// defined in the JSON  object
+		ec, ok := ecm[req.Method]
+		if !ok {
+			err := NewError(MethodNotFoundError, fmt.Sprintf("Method %s was not found.", req.Method))
+			_ = logger.Log("err", err)
+			return ee(ctx, err)
+		}
+
+		// Decode the JSON "params"
+		reqParams, err := ec.Decode(ctx, req.Params)
+		if err != nil {
+			_ = logger.Log("err", err)
+			return ee(ctx, err)
+		}
+
+		// Call the Endpoint with the params
+		response, err := ec.Endpoint(ctx, reqParams)
+		if err != nil {
+			_ = logger.Log("err", err)
+			return ee(ctx, err)
+		}
+
+		// Encode the response from the Endpoint
+		resParams, err := ec.Encode(ctx, response)
+		if err != nil {
+			_ = logger.Log("err", err)
+			return ee(ctx, err)
+		}
+
+		return Response{
+			ID:      req.ID,
+			JSONRPC: Version,
+			Result:  resParams,
+		}
+	}
+}
+
+// Dispatcher answers the requests of JSON RPC messages with a Handler. It
+// decodes a message, runs its requests on a bounded number of goroutines and
+// collects their responses, leaving out those to notifications. Invalid
+// requests are not run, but always answered.
+type Dispatcher struct {
+	codec        Codec
+	strict       bool
+	maxBatchSize int
+	concurrency  int
+	ordered      bool
+	errorEncoder ErrorEncoder
+	logger       log.Logger
+}
+
+// NewDispatcher constructs a new Dispatcher.
+func NewDispatcher(options ...DispatcherOption) *Dispatcher {
+	d := &Dispatcher{
+		codec:        StdCodec,
+		concurrency:  1,
+		ordered:      true,
+		errorEncoder: DefaultErrorEncoder,
+		logger:       log.NewNopLogger(),
+	}
+	for _, option := range options {
+		option(d)
+	}
+	return d
+}
+
+// DispatcherOption sets an optional parameter for dispatchers.
+type DispatcherOption func(*Dispatcher)
+
+// DispatcherCodec sets the Codec used to decode messages.
+// By default, StdCodec is used.
+func DispatcherCodec(codec Codec) DispatcherOption {
+	return func(d *Dispatcher) { d.codec = codec }
+}
+
+// DispatcherStrict makes the dispatcher validate every request with
+// DecodeStrict. Invalid requests are answered with an InvalidRequestError,
+// one per invalid entry of a batch, and empty batches are rejected.
+// By default, requests are not validated.
+func DispatcherStrict(strict bool) DispatcherOption {
+	return func(d *Dispatcher) { d.strict = strict }
+}
+
+// DispatcherMaxBatchSize sets the maximum number of requests of a batch.
+// Larger batches are rejected as a whole with an InvalidRequestError.
+// By default, batches are not limited.
+func DispatcherMaxBatchSize(n int) DispatcherOption {
+	return func(d *Dispatcher) { d.maxBatchSize = n }
+}
+
+// DispatcherConcurrency sets the maximum number of requests of a batch
+// handled concurrently. By default, they are handled one after the other.
+func DispatcherConcurrency(n int) DispatcherOption {
+	return func(d *Dispatcher) { d.concurrency = n }
+}
+
+// DispatcherOrdered sets whether the responses of a batch are returned in the
+// order of the requests, or in the order they complete, which the spec
+// allows. By default, the responses are ordered.
+func DispatcherOrdered(ordered bool) DispatcherOption {
+	return func(d *Dispatcher) { d.ordered = ordered }
+}
+
+// DispatcherErrorEncoder sets the ErrorEncoder of the responses to invalid
+// and timed out requests. By default, DefaultErrorEncoder is used.
+func DispatcherErrorEncoder(ee ErrorEncoder) DispatcherOption {
+	return func(d *Dispatcher) { d.errorEncoder = ee }
+}
+
+// DispatcherErrorLogger is used to log the errors of invalid and timed out
+// requests. By default, no errors are logged.
+func DispatcherErrorLogger(logger log.Logger) DispatcherOption {
+	return func(d *Dispatcher) { d.logger = logger }
+}
+
+// Dispatch decodes the message held by data and answers its requests with h.
+// It returns their responses and whether the message is a batch. The
+// responses are nil if all requests are notifications, and empty if the
+// batch is. The error, which implements ErrorCoder, is returned if the
+// message could not be decoded or is rejected as a whole.
+//
+// When the requests are handled concurrently, those not answered by the time
+// ctx is done are answered with a TimeoutError, without waiting for h.
+func (d *Dispatcher) Dispatch(ctx context.Context, data []byte, h Handler) (responses []Response, batch bool, err error) {
+	reqs, invalid, batch, err := Decode(d.codec, data, d.strict)
+	if err != nil {
+		return nil, false, err
+	}
+	if d.maxBatchSize > 0 && len(reqs) > d.maxBatchSize {
+		return nil, false, NewError(InvalidRequestError, fmt.Sprintf("Batch holds %d requests, more than the maximum of %d.", len(reqs), d.maxBatchSize))
+	}
+	return d.call(ctx, reqs, invalid, h), batch, nil
+}
+
+func (d *Dispatcher) call(ctx context.Context, reqs []Request, invalid []error, h Handler) []Response {
+	if len(reqs) == 0 {
+		return []Response{}
+	}
+
+	invalidAt := func(i int) error {
+		if invalid == nil {
+			return nil
+		}
+		return invalid[i]
+	}
+	answer := func(i int) Response {
+		ctx := context.WithValue(ctx, RequestIDKey, reqs[i].ID)
+		if err := invalidAt(i); err != nil {
+			_ = d.logger.Log("err", err)
+			return d.errorEncoder(ctx, err)
+		}
+		return h(ctx, reqs[i])
+	}
+	// Notifications are run, but get no response. Invalid requests are
+	// always answered.
+	answered := func(i int) bool {
+		return !reqs[i].IsNotification() || invalidAt(i) != nil
+	}
+
+	workers := d.concurrency
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(reqs) {
+		workers = len(reqs)
+	}
+
+	responses := make([]Response, 0, len(reqs))
+	if workers == 1 {
+		for i := range reqs {
+			if res := answer(i); answered(i) {
+				responses = append(responses, res)
+			}
+		}
+		return nilIfEmpty(responses)
+	}
+
+	var (
+		results   = make([]Response, len(reqs))
+		indexes   = make(chan int, len(reqs))
+		completed = make(chan int, len(reqs))
+	)
+	for i := range reqs {
+		indexes <- i
+	}
+	close(indexes)
+	for w := 0; w < workers; w++ {
+		go func() {
+			for i := range indexes {
+				results[i] = answer(i)
+				completed <- i
+			}
+		}()
+	}
+
+	// Requests still running when ctx is done are answered with a timeout
+	// error. Their handlers should return soon, as their context is done.
+	var (
+		done  = make([]bool, len(reqs))
+		order = make([]int, 0, len(reqs))
+	)
+	complete := func(i int) {
+		done[i] = true
+		order = append(order, i)
+	}
+collect:
+	for range reqs {
+		select {
+		case i := <-completed:
+			complete(i)
+		case <-ctx.Done():
+			for {
+				select {
+				case i := <-completed:
+					complete(i)
+				default:
+					break collect
+				}
+			}
+		}
+	}
+	for i := range reqs {
+		if !done[i] {
+			order = append(order, i)
+		}
+	}
+	if d.ordered {
+		for i := range order {
+			order[i] = i
+		}
+	}
+
+	for _, i := range order {
+		if !answered(i) {
+			continue
+		}
+		if done[i] {
+			responses = append(responses, results[i])
+			continue
+		}
+		err := NewError(TimeoutError, "Request was not answered before its deadline: "+ctx.Err().Error())
+		_ = d.logger.Log("err", err)
+		responses = append(responses, d.errorEncoder(context.WithValue(ctx, RequestIDKey, reqs[i].ID), err))
+	}
+	return nilIfEmpty(responses)
+}
+
+func nilIfEmpty(responses []Response) []Response {
+	if len(responses) == 0 {
+		return nil
+	}
+	return responses
+}
@@ -0,0 +1,190 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/l-vitaly/go-kit/transport/jsonrpc"
+)
+
+func testHandler() jsonrpc.Handler {
+	ecm := jsonrpc.EndpointCodecMap{
+		"sleep": jsonrpc.EndpointCodec{
+			Endpoint: func(ctx context.Context, request interface{}) (interface{}, error) {
+				ms := request.(int)
+				if ms < 0 {
+					return nil, errors.New("oof")
+				}
+				select {
+				case <-time.After(time.Duration(ms) * time.Millisecond):
+				case <-ctx.Done():
+				}
+				return ms, nil
+			},
+			Decode: func(_ context.Context, msg json.RawMessage) (interface{}, error) {
+				var ms int
+				err := json.Unmarshal(msg, &ms)
+				return ms, err
+			},
+			Encode: func(_ context.Context, res interface{}) (json.RawMessage, error) { return json.Marshal(res) },
+		},
+	}
+	return jsonrpc.EndpointHandler(ecm, jsonrpc.DefaultErrorEncoder, log.NewNopLogger())
+}
+
+func responseIDs(t *testing.T, responses []jsonrpc.Response) []int {
+	t.Helper()
+	ids := make([]int, len(responses))
+	for i, res := range responses {
+		id, err := res.ID.Int()
+		if err != nil {
+			t.Fatalf("response %d: %v", i, err)
+		}
+		ids[i] = id
+	}
+	return ids
+}
+
+func TestDispatcherDispatch(t *testing.T) {
+	sut := jsonrpc.NewDispatcher()
+	for _, tc := range []struct {
+		name  string
+		in    string
+		batch bool
+		want  []int // IDs of the responses, nil for none
+		code  int   // code of the error of the first response, if any
+	}{
+		{"single", `{"jsonrpc": "2.0", "method": "sleep", "params": 0, "id": 1}`, false, []int{1}, 0},
+		{"failing", `{"jsonrpc": "2.0", "method": "sleep", "params": -1, "id": 1}`, false, []int{1}, jsonrpc.InternalError},
+		{"unknown method", `{"jsonrpc": "2.0", "method": "nope", "id": 1}`, false, []int{1}, jsonrpc.MethodNotFoundError},
+		{"notification", `{"jsonrpc": "2.0", "method": "sleep", "params": 0}`, false, nil, 0},
+		{"batch", `[{"jsonrpc": "2.0", "method": "sleep", "params": 0, "id": 1}, {"jsonrpc": "2.0", "method": "sleep", "params": 0}, {"jsonrpc": "2.0", "method": "sleep", "params": 0, "id": 2}]`, true, []int{1, 2}, 0},
+		{"notification batch", `[{"jsonrpc": "2.0", "method": "sleep", "params": 0}]`, true, nil, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			responses, batch, err := sut.Dispatch(context.Background(), []byte(tc.in), testHandler())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want, have := tc.batch, batch; want != have {
+				t.Errorf("batch: want %v, have %v", want, have)
+			}
+			if tc.want == nil {
+				if responses != nil {
+					t.Fatalf("want no responses, have %+v", responses)
+				}
+				return
+			}
+			if want, have := tc.want, responseIDs(t, responses); len(want) != len(have) || want[0] != have[0] || want[len(want)-1] != have[len(have)-1] {
+				t.Fatalf("want IDs %v, have %v", want, have)
+			}
+			if tc.code != 0 && (responses[0].Error == nil || responses[0].Error.Code != tc.code) {
+				t.Fatalf("want error code %d, have %+v", tc.code, responses[0].Error)
+			}
+		})
+	}
+
+	if responses, _, err := sut.Dispatch(context.Background(), []byte(`[]`), testHandler()); err != nil || responses == nil || len(responses) != 0 {
+		t.Errorf("empty batch: want no error and empty responses, have %v, %+v", err, responses)
+	}
+	if _, _, err := sut.Dispatch(context.Background(), []byte(`{"jsonrpc":`), testHandler()); err == nil || err.(jsonrpc.ErrorCoder).ErrorCode() != jsonrpc.ParseError {
+		t.Errorf("want parse error, have %v", err)
+	}
+}
+
+func TestDispatcherStrict(t *testing.T) {
+	sut := jsonrpc.NewDispatcher(jsonrpc.DispatcherStrict(true), jsonrpc.DispatcherMaxBatchSize(2))
+
+	responses, _, err := sut.Dispatch(context.Background(), []byte(`[{"jsonrpc": "1.0", "method": "sleep", "id": 1}, {"jsonrpc": "2.0", "method": "run"}]`), testHandler())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(responses) != 1 || responses[0].Error == nil || responses[0].Error.Code != jsonrpc.InvalidRequestError {
+		t.Fatalf("want one invalid request error, have %+v", responses)
+	}
+
+	for _, in := range []string{`[]`, `[1, 2, 3]`} {
+		if _, _, err := sut.Dispatch(context.Background(), []byte(in), testHandler()); err == nil || err.(jsonrpc.ErrorCoder).ErrorCode() != jsonrpc.InvalidRequestError {
+			t.Errorf("%s: want invalid request error, have %v", in, err)
+		}
+	}
+}
+
+func TestDispatcherConcurrency(t *testing.T) {
+	batch := []byte(`[
+		{"jsonrpc": "2.0", "method": "sleep", "params": 60, "id": 1},
+		{"jsonrpc": "2.0", "method": "sleep", "params": 0, "id": 2},
+		{"jsonrpc": "2.0", "method": "sleep", "params": 30, "id": 3}
+	]`)
+
+	var active, maxActive int32
+	handler := func(h jsonrpc.Handler) jsonrpc.Handler {
+		return func(ctx context.Context, req jsonrpc.Request) jsonrpc.Response {
+			n := atomic.AddInt32(&active, 1)
+			defer atomic.AddInt32(&active, -1)
+			for {
+				m := atomic.LoadInt32(&maxActive)
+				if n <= m || atomic.CompareAndSwapInt32(&maxActive, m, n) {
+					break
+				}
+			}
+			return h(ctx, req)
+		}
+	}(testHandler())
+
+	ordered := jsonrpc.NewDispatcher(jsonrpc.DispatcherConcurrency(3))
+	responses, _, err := ordered.Dispatch(context.Background(), batch, handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := []int{1, 2, 3}, responseIDs(t, responses); want[0] != have[0] || want[1] != have[1] || want[2] != have[2] {
+		t.Errorf("ordered: want IDs %v, have %v", want, have)
+	}
+	if atomic.LoadInt32(&maxActive) < 2 {
+		t.Errorf("want requests handled concurrently, max active %d", maxActive)
+	}
+
+	unordered := jsonrpc.NewDispatcher(jsonrpc.DispatcherConcurrency(3), jsonrpc.DispatcherOrdered(false))
+	responses, _, err = unordered.Dispatch(context.Background(), batch, handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := []int{2, 3, 1}, responseIDs(t, responses); want[0] != have[0] || want[2] != have[2] {
+		t.Errorf("unordered: want IDs %v, have %v", want, have)
+	}
+}
+
+func TestDispatcherTimeout(t *testing.T) {
+	sut := jsonrpc.NewDispatcher(jsonrpc.DispatcherConcurrency(2))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	block := make(chan struct{})
+	defer close(block)
+	handler := func(ctx context.Context, req jsonrpc.Request) jsonrpc.Response {
+		if req.Method == "block" {
+			<-block
+		}
+		return jsonrpc.Response{JSONRPC: jsonrpc.Version, ID: req.ID, Result: json.RawMessage("true")}
+	}
+
+	responses, _, err := sut.Dispatch(ctx, []byte(`[{"jsonrpc": "2.0", "method": "block", "id": 1}, {"jsonrpc": "2.0", "method": "run", "id": 2}]`), handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(responses) != 2 {
+		t.Fatalf("want 2 responses, have %+v", responses)
+	}
+	if res := responses[0]; res.Error == nil || res.Error.Code != jsonrpc.TimeoutError {
+		t.Errorf("want timeout error for the blocked request, have %+v", res)
+	}
+	if res := responses[1]; res.Error != nil {
+		t.Errorf("want result for the other request, have %+v", res.Error)
+	}
+}
@@ -0,0 +1,12 @@
+// Package jsonrpc implements the transport independent parts of JSON RPC
+// (v2.0): the request, response and error objects, the validation of
+// requests, and a Dispatcher which answers the requests of a message with
+// endpoints. The HTTP, FastHTTP and WebSocket bindings are built on it.
+//
+// Server exposes an EndpointCodecMap over any byte stream: the standard
+// input and output of a command line tool, or the TCP and Unix socket
+// connections of local agents. Messages are delimited by newlines, or by
+// Content-Length headers as in the Language Server Protocol.
+//
+// See http://www.jsonrpc.org/specification
+package jsonrpc
@@ -0,0 +1,127 @@
+package jsonrpc
+
+import "context"
+
+// Error defines a JSON RPC error that can be returned
+// in a Response from the spec
+// http://www.jsonrpc.org/specification#error_object
+type Error struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// NewError returns an Error with the given code and message.
+func NewError(code int, message string) Error {
+	return Error{Code: code, Message: message}
+}
+
+// Error implements error.
+func (e Error) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return errorMessage[e.Code]
+}
+
+// ErrorCode returns the JSON RPC error code associated with the error.
+func (e Error) ErrorCode() int {
+	return e.Code
+}
+
+const (
+	// ParseError defines invalid JSON was received by the server.
+	// An error occurred on the server while parsing the JSON text.
+	ParseError int = -32700
+
+	// InvalidRequestError defines the JSON sent is not a valid Request object.
+	InvalidRequestError int = -32600
+
+	// MethodNotFoundError defines the method does not exist / is not available.
+	MethodNotFoundError int = -32601
+
+	// InvalidParamsError defines invalid method parameter(s).
+	InvalidParamsError int = -32602
+
+	// InternalError defines a server error
+	InternalError int = -32603
+
+	// TimeoutError defines the request was not answered before its deadline.
+	// It is one of the codes reserved for implementation-defined server
+	// errors.
+	TimeoutError int = -32000
+)
+
+var errorMessage = map[int]string{
+	ParseError:          "An error occurred on the server while parsing the JSON text.",
+	InvalidRequestError: "The JSON sent is not a valid Request object.",
+	MethodNotFoundError: "The method does not exist / is not available.",
+	InvalidParamsError:  "Invalid method parameter(s).",
+	InternalError:       "Internal JSON-RPC error.",
+	TimeoutError:        "The request was not answered before its deadline.",
+}
+
+// ErrorMessage returns a message for the JSON RPC error code. It returns the empty
+// string if the code is unknown.
+func ErrorMessage(code int) string {
+	return errorMessage[code]
+}
+
+// ErrorCoder is checked by DefaultErrorEncoder. If an error value implements
+// ErrorCoder, the integer result of ErrorCode() will be used as the JSONRPC
+// error code when encoding the error.
+//
+// By default, InternalError (-32603) is used.
+type ErrorCoder interface {
+	ErrorCode() int
+}
+
+// ErrorData is checked by DefaultErrorEncoder. If an error value implements
+// ErrorData, the interface{} result of ErrorData() will be used as the JSONRPC
+// error data when encoding the error.
+//
+// By default, empty is used.
+type ErrorData interface {
+	ErrorData() interface{}
+}
+
+type requestIDKeyType struct{}
+
+// RequestIDKey is the context key of the *RequestID of the request being
+// answered.
+var RequestIDKey requestIDKeyType
+
+// ErrorEncoder is responsible for encoding an error to the JSON RPC response
+// of the request whose ID is found in ctx.
+type ErrorEncoder func(ctx context.Context, err error) Response
+
+// DefaultErrorEncoder encodes the error as a json-rpc error response, with an
+// InternalError code, for the request ID found in ctx.
+// The Error() string of the error will be used as the response error message.
+// If the error implements ErrorCoder, the provided code will be set on the
+// response error.
+// If the error implements ErrorData, the provided data will be set on the
+// response error.
+func DefaultErrorEncoder(ctx context.Context, err error) Response {
+	e := Error{
+		Code:    InternalError,
+		Message: err.Error(),
+	}
+	if sc, ok := err.(ErrorCoder); ok {
+		e.Code = sc.ErrorCode()
+	}
+	if sc, ok := err.(ErrorData); ok {
+		e.Data = sc.ErrorData()
+	}
+
+	var requestID *RequestID
+	if v := ctx.Value(RequestIDKey); v != nil {
+		requestID = v.(*RequestID)
+	}
+
+	return Response{
+		ID:      requestID,
+		JSONRPC: Version,
+		Error:   &e,
+	}
+}
@@ -27,11 +27,12 @@ func TestError(t *testing.T) {
 }
 func TestErrorsSatisfyError(t *testing.T) {
 	errs := []interface{}{
-		parseError("parseError"),
-		invalidRequestError("invalidRequestError"),
-		methodNotFoundError("methodNotFoundError"),
-		invalidParamsError("invalidParamsError"),
-		internalError("internalError"),
+		NewError(ParseError, "parseError"),
+		NewError(InvalidRequestError, "invalidRequestError"),
+		NewError(MethodNotFoundError, "methodNotFoundError"),
+		NewError(InvalidParamsError, "invalidParamsError"),
+		NewError(InternalError, "internalError"),
+		NewError(TimeoutError, "timeoutError"),
 	}
 	for _, e := range errs {
 		err, ok := e.(error)
@@ -0,0 +1,125 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// ErrMessageTooLarge is returned by a Framing reading a message larger than
+// the maximum size it is given.
+var ErrMessageTooLarge = errors.New("jsonrpc: message too large")
+
+// Framing delimits the messages of a byte stream.
+type Framing interface {
+	// ReadMessage reads the next message from r. It returns io.EOF once
+	// the stream ends between two messages. If max is positive, messages
+	// larger than max bytes are not read, and ErrMessageTooLarge is
+	// returned instead.
+	ReadMessage(r *bufio.Reader, max int) ([]byte, error)
+	// WriteMessage writes msg to w as a single message.
+	WriteMessage(w io.Writer, msg []byte) error
+}
+
+// NewlineFraming delimits messages by newlines, one JSON text per line, as
+// JSON text sequences and most command line tools do. Blank lines are
+// skipped.
+var NewlineFraming Framing = newlineFraming{}
+
+// ContentLengthFraming prefixes each message with a header holding its
+// length, as the Language Server Protocol does:
+//
+//	Content-Length: 42\r\n
+//	\r\n
+//	{"jsonrpc": "2.0", ...}
+//
+// Other header fields, like Content-Type, are read and ignored.
+var ContentLengthFraming Framing = contentLengthFraming{}
+
+type newlineFraming struct{}
+
+func (newlineFraming) ReadMessage(r *bufio.Reader, max int) ([]byte, error) {
+	for {
+		line, err := readLine(r, max)
+		if msg := bytes.TrimSpace(line); len(msg) > 0 {
+			// The last message of a stream needs no newline.
+			return msg, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readLine reads a line, newline included, failing once it holds more than
+// max bytes besides the newline, if max is positive.
+func readLine(r *bufio.Reader, max int) ([]byte, error) {
+	var line []byte
+	for {
+		frag, err := r.ReadSlice('\n')
+		n := len(line) + len(frag)
+		if err == nil {
+			n--
+		}
+		if max > 0 && n > max {
+			return nil, ErrMessageTooLarge
+		}
+		line = append(line, frag...)
+		if err != bufio.ErrBufferFull {
+			return line, err
+		}
+	}
+}
+
+func (newlineFraming) WriteMessage(w io.Writer, msg []byte) error {
+	if bytes.IndexByte(msg, '\n') >= 0 {
+		return errors.New("jsonrpc: message holds a newline")
+	}
+	_, err := w.Write(append(msg[:len(msg):len(msg)], '\n'))
+	return err
+}
+
+type contentLengthFraming struct{}
+
+func (contentLengthFraming) ReadMessage(r *bufio.Reader, max int) ([]byte, error) {
+	header, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil {
+		if err == io.EOF && len(header) > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	v := header.Get("Content-Length")
+	if v == "" {
+		return nil, errors.New("jsonrpc: message header has no Content-Length")
+	}
+	n, err := strconv.ParseUint(strings.TrimSpace(v), 10, 31)
+	if err != nil {
+		return nil, fmt.Errorf("jsonrpc: invalid Content-Length %q", v)
+	}
+	if max > 0 && n > uint64(max) {
+		return nil, ErrMessageTooLarge
+	}
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return msg, nil
+}
+
+func (contentLengthFraming) WriteMessage(w io.Writer, msg []byte) error {
+	buf := make([]byte, 0, len(msg)+32)
+	buf = append(buf, "Content-Length: "...)
+	buf = strconv.AppendInt(buf, int64(len(msg)), 10)
+	buf = append(buf, "\r\n\r\n"...)
+	_, err := w.Write(append(buf, msg...))
+	return err
+}
@@ -0,0 +1,223 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Request defines a JSON RPC request from the spec
+// http://www.jsonrpc.org/specification#request_object
+type Request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	ID      *RequestID      `json:"id"`
+}
+
+// IsNotification reports whether the request is a notification, which the
+// server must not answer, not even with an error. A request with a null ID
+// is not a notification.
+func (r Request) IsNotification() bool {
+	return r.ID == nil
+}
+
+// UnmarshalJSON satisfies json.Unmarshaler. Unlike the default decoding of
+// pointers, it keeps a null id apart from an absent one: ID is nil only if
+// the request has no id member.
+func (r *Request) UnmarshalJSON(b []byte) error {
+	type request Request
+	var v struct {
+		request
+		ID json.RawMessage `json:"id"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*r = Request(v.request)
+	r.ID = nil
+	if v.ID != nil {
+		r.ID = new(RequestID)
+		return r.ID.UnmarshalJSON(v.ID)
+	}
+	return nil
+}
+
+// Notification defines a JSON RPC notification, a request without an ID,
+// from the spec http://www.jsonrpc.org/specification#notification
+type Notification struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// RequestID defines a request ID that can be string, number, or null.
+// An identifier established by the Client that MUST contain a String,
+// Number, or NULL value if included.
+// If it is not included it is assumed to be a notification.
+// The value SHOULD normally not be Null and
+// Numbers SHOULD NOT contain fractional parts.
+// The RequestID keeps the exact JSON it was read from, so that it is echoed
+// back unchanged and large numbers do not lose precision.
+type RequestID struct {
+	raw json.RawMessage
+}
+
+// errNullRequestID is returned when a null ID is read as a value.
+var errNullRequestID = errors.New("jsonrpc: request id is null")
+
+// NewRequestID returns the ID encoding v, which must marshal to a JSON
+// string, number or null, like the values of a RequestIDGenerator.
+func NewRequestID(v interface{}) (*RequestID, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	id := new(RequestID)
+	if err := id.UnmarshalJSON(b); err != nil {
+		return nil, err
+	}
+	return id, nil
+}
+
+// NewInt64RequestID returns a number ID.
+func NewInt64RequestID(v int64) *RequestID {
+	return &RequestID{raw: json.RawMessage(strconv.FormatInt(v, 10))}
+}
+
+// NewUint64RequestID returns a number ID.
+func NewUint64RequestID(v uint64) *RequestID {
+	return &RequestID{raw: json.RawMessage(strconv.FormatUint(v, 10))}
+}
+
+// NewStringRequestID returns a string ID.
+func NewStringRequestID(v string) *RequestID {
+	return &RequestID{raw: json.RawMessage(strconv.Quote(v))}
+}
+
+// NewNullRequestID returns a null ID, the ID of responses to requests whose
+// ID could not be read.
+func NewNullRequestID() *RequestID {
+	return &RequestID{raw: json.RawMessage("null")}
+}
+
+// UnmarshalJSON satisfies json.Unmarshaler. It returns an error if b is
+// not a JSON string, number or null.
+func (id *RequestID) UnmarshalJSON(b []byte) error {
+	b = bytes.TrimSpace(b)
+	if len(b) == 0 || !isValidID(b) {
+		return fmt.Errorf("jsonrpc: invalid request id %s", b)
+	}
+	id.raw = append(id.raw[:0], b...)
+	return nil
+}
+
+// MarshalJSON satisfies json.Marshaler. It returns the JSON the ID was
+// read from.
+func (id *RequestID) MarshalJSON() ([]byte, error) {
+	if id == nil || len(id.raw) == 0 {
+		return []byte("null"), nil
+	}
+	return id.raw, nil
+}
+
+// IsNull reports whether the ID is null. A nil ID, which stands for an
+// absent one, is not null.
+func (id *RequestID) IsNull() bool {
+	return id != nil && (len(id.raw) == 0 || string(id.raw) == "null")
+}
+
+// Equal reports whether id and other are the same ID. Strings are compared
+// by value, numbers as written.
+func (id *RequestID) Equal(other *RequestID) bool {
+	return id.Key() == other.Key()
+}
+
+// Key returns a form of the ID that is equal for equal IDs, to index maps
+// by ID. String IDs keep their quotes, so that "1" and 1 yield different
+// keys. The key of a nil ID is empty.
+func (id *RequestID) Key() string {
+	switch {
+	case id == nil:
+		return ""
+	case id.IsNull():
+		return "null"
+	case id.raw[0] == '"':
+		var s string
+		if err := json.Unmarshal(id.raw, &s); err == nil {
+			return strconv.Quote(s)
+		}
+	}
+	return string(id.raw)
+}
+
+func (id *RequestID) decode(v interface{}) error {
+	if id.IsNull() {
+		return errNullRequestID
+	}
+	return json.Unmarshal(id.raw, v)
+}
+
+// Int returns the ID as an integer value.
+// An error is returned if the ID can't be treated as an int.
+func (id *RequestID) Int() (int, error) {
+	var v int
+	err := id.decode(&v)
+	return v, err
+}
+
+// Int64 returns the ID as an int64 value.
+// An error is returned if the ID can't be treated as an int64.
+func (id *RequestID) Int64() (int64, error) {
+	var v int64
+	err := id.decode(&v)
+	return v, err
+}
+
+// Uint64 returns the ID as an uint64 value.
+// An error is returned if the ID can't be treated as an uint64.
+func (id *RequestID) Uint64() (uint64, error) {
+	var v uint64
+	err := id.decode(&v)
+	return v, err
+}
+
+// Float32 returns the ID as a float value.
+// An error is returned if the ID can't be treated as an float.
+func (id *RequestID) Float32() (float32, error) {
+	var v float32
+	err := id.decode(&v)
+	return v, err
+}
+
+// String returns the ID as a string value.
+// An error is returned if the ID can't be treated as an string.
+func (id *RequestID) String() (string, error) {
+	var v string
+	err := id.decode(&v)
+	return v, err
+}
+
+// Response defines a JSON RPC response from the spec
+// http://www.jsonrpc.org/specification#response_object
+// Stream is an extension of the spec used by transports that push several
+// responses for a single request: it is set on the frames of an open stream.
+// The terminal frame of a stream has Stream unset and carries either an error
+// or no result.
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+	ID      *RequestID      `json:"id"`
+	Stream  bool            `json:"stream,omitempty"`
+}
+
+const (
+	// Version defines the version of the JSON RPC implementation
+	Version string = "2.0"
+
+	// ContentType defines the content type to be served.
+	ContentType string = "application/json; charset=utf-8"
+)
@@ -0,0 +1,52 @@
+package jsonrpc_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/l-vitaly/go-kit/transport/jsonrpc"
+)
+
+func TestRequestNotification(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want bool
+	}{
+		{`{"jsonrpc": "2.0", "method": "foo"}`, true},
+		{`{"jsonrpc": "2.0", "method": "foo", "id": null}`, false},
+		{`{"jsonrpc": "2.0", "method": "foo", "id": 1}`, false},
+	} {
+		var r jsonrpc.Request
+		if err := json.Unmarshal([]byte(tc.in), &r); err != nil {
+			t.Fatal(err)
+		}
+		if want, have := tc.want, r.IsNotification(); want != have {
+			t.Errorf("%s: want notification %v, have %v", tc.in, want, have)
+		}
+	}
+}
+
+func TestRequestIDKey(t *testing.T) {
+	for _, tc := range []struct {
+		id   *jsonrpc.RequestID
+		want string
+	}{
+		{nil, ""},
+		{jsonrpc.NewNullRequestID(), "null"},
+		{jsonrpc.NewInt64RequestID(42), "42"},
+		{jsonrpc.NewStringRequestID("42"), `"42"`},
+		{jsonrpc.NewStringRequestID("A"), `"A"`},
+	} {
+		if want, have := tc.want, tc.id.Key(); want != have {
+			t.Errorf("want key %q, have %q", want, have)
+		}
+	}
+
+	var id jsonrpc.RequestID
+	if err := json.Unmarshal([]byte(`"A"`), &id); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := jsonrpc.NewStringRequestID("A").Key(), id.Key(); want != have {
+		t.Errorf("escaped string id: want key %q, have %q", want, have)
+	}
+}
@@ -0,0 +1,305 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+)
+
+// connConcurrencyDefault is the default number of messages of a stream
+// handled concurrently.
+const connConcurrencyDefault = 16
+
+// maxMessageSizeDefault is the default maximum size in bytes of the messages
+// read from streams.
+const maxMessageSizeDefault = 4 << 20
+
+// Server answers JSON RPC messages with the endpoints of an EndpointCodecMap,
+// independently of the transport carrying them. Handle answers a single
+// message, while ServeConn, Serve and ServeStdio read and answer the messages
+// of byte streams, delimited by a Framing.
+type Server struct {
+	ecm             EndpointCodecMap
+	framing         Framing
+	maxMessageSize  int
+	codec           Codec
+	strict          bool
+	maxBatchSize    int
+	concurrency     int
+	connConcurrency int
+	errorEncoder    ErrorEncoder
+	logger          log.Logger
+
+	handler    Handler
+	dispatcher *Dispatcher
+}
+
+// NewServer constructs a new server.
+func NewServer(ecm EndpointCodecMap, options ...ServerOption) *Server {
+	s := &Server{
+		ecm:             ecm,
+		framing:         NewlineFraming,
+		maxMessageSize:  maxMessageSizeDefault,
+		codec:           StdCodec,
+		concurrency:     1,
+		connConcurrency: connConcurrencyDefault,
+		errorEncoder:    DefaultErrorEncoder,
+		logger:          log.NewNopLogger(),
+	}
+	for _, option := range options {
+		option(s)
+	}
+	s.handler = EndpointHandler(s.ecm, s.errorEncoder, s.logger)
+	s.dispatcher = NewDispatcher(
+		DispatcherCodec(s.codec),
+		DispatcherStrict(s.strict),
+		DispatcherMaxBatchSize(s.maxBatchSize),
+		DispatcherConcurrency(s.concurrency),
+		DispatcherErrorEncoder(s.errorEncoder),
+		DispatcherErrorLogger(s.logger),
+	)
+	return s
+}
+
+// ServerOption sets an optional parameter for servers.
+type ServerOption func(*Server)
+
+// ServerFraming sets the Framing delimiting the messages of streams.
+// By default, NewlineFraming is used.
+func ServerFraming(framing Framing) ServerOption {
+	return func(s *Server) { s.framing = framing }
+}
+
+// ServerMaxMessageSize sets the maximum size in bytes of the messages read
+// from streams. A larger message ends its stream with ErrMessageTooLarge,
+// before it is read. A non-positive n lifts the limit.
+// By default, messages are limited to 4 MiB.
+func ServerMaxMessageSize(n int) ServerOption {
+	return func(s *Server) { s.maxMessageSize = n }
+}
+
+// ServerCodec sets the Codec of the messages. By default, StdCodec is used.
+func ServerCodec(codec Codec) ServerOption {
+	return func(s *Server) { s.codec = codec }
+}
+
+// ServerStrict makes the server validate every request, see
+// DispatcherStrict. By default, requests are not validated.
+func ServerStrict(strict bool) ServerOption {
+	return func(s *Server) { s.strict = strict }
+}
+
+// ServerMaxBatchSize sets the maximum number of requests of a batch.
+// By default, batches are not limited.
+func ServerMaxBatchSize(n int) ServerOption {
+	return func(s *Server) { s.maxBatchSize = n }
+}
+
+// ServerBatchConcurrency sets the maximum number of requests of a batch
+// handled concurrently. By default, they are handled one after the other.
+func ServerBatchConcurrency(n int) ServerOption {
+	return func(s *Server) { s.concurrency = n }
+}
+
+// ServerConnConcurrency sets the maximum number of messages of a stream
+// handled concurrently. Once it is reached, the next message is read only
+// when one is answered. A value less than 1 makes the messages handled one
+// after the other.
+// By default, 16 messages of a stream are handled concurrently.
+func ServerConnConcurrency(n int) ServerOption {
+	return func(s *Server) { s.connConcurrency = n }
+}
+
+// ServerErrorEncoder is used to encode errors to the client.
+// By default, DefaultErrorEncoder is used.
+func ServerErrorEncoder(ee ErrorEncoder) ServerOption {
+	return func(s *Server) { s.errorEncoder = ee }
+}
+
+// ServerErrorLogger is used to log non-terminal errors. By default, no errors
+// are logged.
+func ServerErrorLogger(logger log.Logger) ServerOption {
+	return func(s *Server) { s.logger = logger }
+}
+
+// Handle answers the JSON RPC message held by data, a single request or a
+// batch. It returns the encoded response, or nil if there is nothing to
+// answer, that is if the message holds notifications only. Messages which
+// can't be decoded are answered with an error response with a null ID.
+func (s *Server) Handle(ctx context.Context, data []byte) ([]byte, error) {
+	responses, batch, err := s.dispatcher.Dispatch(ctx, data, s.handler)
+	if err != nil {
+		_ = s.logger.Log("err", err)
+		return s.codec.Marshal(s.errorEncoder(ctx, err))
+	}
+	if responses == nil {
+		return nil, nil
+	}
+	if !batch {
+		return s.codec.Marshal(responses[0])
+	}
+	return s.codec.Marshal(responses)
+}
+
+// ServeConn reads the messages of rw and writes their responses back, until
+// the stream ends or ctx is done. Messages are answered concurrently, up to
+// the limit set by ServerConnConcurrency, so the responses are written in the
+// order they complete. It returns nil once the stream ends and all messages
+// are answered, or the error which ended it, like ErrMessageTooLarge.
+// The context of the endpoints is canceled when ServeConn returns.
+func (s *Server) ServeConn(ctx context.Context, rw io.ReadWriter) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg   sync.WaitGroup
+		mtx  sync.Mutex // serializes the writes to rw
+		werr error
+	)
+	// Stop reading once ctx is done, if rw can be closed.
+	if c, ok := rw.(io.Closer); ok {
+		stop := make(chan struct{})
+		defer close(stop)
+		go func() {
+			select {
+			case <-ctx.Done():
+				_ = c.Close()
+			case <-stop:
+			}
+		}()
+	}
+
+	// done waits for the messages being answered, and returns the error
+	// which ended the stream.
+	done := func(err error) error {
+		wg.Wait()
+		if err == io.EOF {
+			err = nil
+		}
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		if werr != nil {
+			err = werr
+		}
+		return err
+	}
+
+	slots := s.connConcurrency
+	if slots < 1 {
+		slots = 1
+	}
+	sem := make(chan struct{}, slots)
+
+	r := bufio.NewReader(rw)
+	for {
+		msg, err := s.framing.ReadMessage(r, s.maxMessageSize)
+		if err != nil {
+			return done(err)
+		}
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return done(nil)
+		}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			res, err := s.Handle(ctx, msg)
+			if err != nil {
+				_ = s.logger.Log("err", err)
+				return
+			}
+			if res == nil {
+				return
+			}
+			mtx.Lock()
+			defer mtx.Unlock()
+			if werr != nil {
+				return
+			}
+			if werr = s.framing.WriteMessage(rw, res); werr != nil {
+				_ = s.logger.Log("err", werr)
+				cancel()
+			}
+		}()
+	}
+}
+
+// Serve accepts connections on l, a TCP or Unix socket listener for
+// instance, and serves each of them with ServeConn on its own goroutine.
+// Accept errors of a listener still open, such as running out of file
+// descriptors, are retried with a backoff capped to a second, as net/http
+// does. Once l is closed or fails otherwise, the served connections are
+// closed, and Serve returns the error of l when they are done.
+func (s *Server) Serve(l net.Listener) error {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var delay time.Duration // how long to sleep on accept failure
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			var ne net.Error
+			if errors.Is(err, net.ErrClosed) || !errors.As(err, &ne) {
+				return err
+			}
+			if delay == 0 {
+				delay = 5 * time.Millisecond
+			} else {
+				delay *= 2
+			}
+			if max := time.Second; delay > max {
+				delay = max
+			}
+			_ = s.logger.Log("err", err, "retry", delay)
+			time.Sleep(delay)
+			continue
+		}
+		delay = 0
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer conn.Close()
+			if err := s.ServeConn(ctx, conn); err != nil {
+				_ = s.logger.Log("err", err, "remote", conn.RemoteAddr())
+			}
+		}()
+	}
+}
+
+// ListenAndServe listens on the network address, which may be "tcp" or
+// "unix" for instance, and then calls Serve.
+func (s *Server) ListenAndServe(network, address string) error {
+	l, err := net.Listen(network, address)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+	return s.Serve(l)
+}
+
+// ServeStdio serves the messages read from the standard input, writing their
+// responses to the standard output, as ServeConn does. As the standard input
+// can't be closed, it returns only once the input ends, even if ctx is done.
+func (s *Server) ServeStdio(ctx context.Context) error {
+	return s.ServeConn(ctx, stdio{})
+}
+
+type stdio struct{}
+
+func (stdio) Read(p []byte) (int, error)  { return os.Stdin.Read(p) }
+func (stdio) Write(p []byte) (int, error) { return os.Stdout.Write(p) }
@@ -0,0 +1,306 @@
+package jsonrpc_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/l-vitaly/go-kit/transport/jsonrpc"
+)
+
+func addEndpoint() jsonrpc.EndpointCodecMap {
+	return jsonrpc.EndpointCodecMap{
+		"add": jsonrpc.EndpointCodec{
+			Endpoint: func(_ context.Context, request interface{}) (interface{}, error) {
+				ns := request.([]int)
+				sum := 0
+				for _, n := range ns {
+					sum += n
+				}
+				return sum, nil
+			},
+			Decode: func(_ context.Context, msg json.RawMessage) (interface{}, error) {
+				var ns []int
+				err := json.Unmarshal(msg, &ns)
+				return ns, err
+			},
+			Encode: func(_ context.Context, res interface{}) (json.RawMessage, error) { return json.Marshal(res) },
+		},
+	}
+}
+
+func TestServerHandle(t *testing.T) {
+	sut := jsonrpc.NewServer(addEndpoint())
+	for _, tc := range []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"single", `{"jsonrpc": "2.0", "method": "add", "params": [1, 2], "id": 1}`, `{"jsonrpc":"2.0","result":3,"id":1}`},
+		{"batch", `[{"jsonrpc": "2.0", "method": "add", "params": [1, 2], "id": 1}, {"jsonrpc": "2.0", "method": "add", "params": [3]}]`, `[{"jsonrpc":"2.0","result":3,"id":1}]`},
+		{"notification", `{"jsonrpc": "2.0", "method": "add", "params": [1, 2]}`, ``},
+		{"unknown method", `{"jsonrpc": "2.0", "method": "sub", "id": "a"}`, `{"jsonrpc":"2.0","error":{"code":-32601,"message":"Method sub was not found."},"id":"a"}`},
+		{"parse error", `{"jsonrpc": "2.0",`, `{"jsonrpc":"2.0","error":{"code":-32700,"message":"JSON could not be decoded: unexpected end of JSON input"},"id":null}`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := sut.Handle(context.Background(), []byte(tc.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want, have := tc.want, string(res); want != have {
+				t.Errorf("want %s, have %s", want, have)
+			}
+		})
+	}
+}
+
+type readWriter struct {
+	io.Reader
+	io.Writer
+}
+
+func TestServerServeConn(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		framing jsonrpc.Framing
+		in      string
+		want    []string
+	}{
+		{
+			name:    "newline",
+			framing: jsonrpc.NewlineFraming,
+			in:      "{\"jsonrpc\": \"2.0\", \"method\": \"add\", \"params\": [1, 2], \"id\": 1}\n\n{\"jsonrpc\": \"2.0\", \"method\": \"add\", \"params\": [1]}\n{\"jsonrpc\": \"2.0\", \"method\": \"add\", \"params\": [2, 2], \"id\": 2}",
+			want:    []string{`{"jsonrpc":"2.0","result":3,"id":1}`, `{"jsonrpc":"2.0","result":4,"id":2}`},
+		},
+		{
+			name:    "content length",
+			framing: jsonrpc.ContentLengthFraming,
+			in:      "Content-Length: 62\r\nContent-Type: application/vscode-jsonrpc; charset=utf-8\r\n\r\n{\"jsonrpc\": \"2.0\", \"method\": \"add\", \"params\": [1, 2], \"id\": 1}content-length:62\r\n\r\n{\"jsonrpc\": \"2.0\", \"method\": \"add\", \"params\": [2, 2], \"id\": 2}",
+			want:    []string{`{"jsonrpc":"2.0","result":3,"id":1}`, `{"jsonrpc":"2.0","result":4,"id":2}`},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			sut := jsonrpc.NewServer(addEndpoint(), jsonrpc.ServerFraming(tc.framing))
+			if err := sut.ServeConn(context.Background(), readWriter{strings.NewReader(tc.in), &out}); err != nil {
+				t.Fatal(err)
+			}
+
+			var have []string
+			r := bufio.NewReader(&out)
+			for {
+				msg, err := tc.framing.ReadMessage(r, 0)
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				have = append(have, string(msg))
+			}
+			// Messages are answered concurrently.
+			sort.Strings(have)
+			if want := tc.want; strings.Join(want, "\n") != strings.Join(have, "\n") {
+				t.Errorf("want %q, have %q", want, have)
+			}
+		})
+	}
+}
+
+func TestServerServeConnFramingError(t *testing.T) {
+	sut := jsonrpc.NewServer(addEndpoint(), jsonrpc.ServerFraming(jsonrpc.ContentLengthFraming))
+	for _, in := range []string{
+		"Content-Type: application/json\r\n\r\n{}",
+		"Content-Length: x\r\n\r\n{}",
+		"Content-Length: 10\r\n\r\n{}",
+	} {
+		if err := sut.ServeConn(context.Background(), readWriter{strings.NewReader(in), ioutil.Discard}); err == nil {
+			t.Errorf("%q: want error, have none", in)
+		}
+	}
+}
+
+func TestServerMaxMessageSize(t *testing.T) {
+	long := `{"jsonrpc": "2.0", "method": "add", "params": [1, 2], "id": "` + strings.Repeat("x", 8000) + `"}`
+	for _, tc := range []struct {
+		name    string
+		framing jsonrpc.Framing
+		max     int
+		in      string
+		want    error
+	}{
+		{"newline", jsonrpc.NewlineFraming, len(long), long + "\n", nil},
+		{"newline too large", jsonrpc.NewlineFraming, len(long) - 1, long + "\n", jsonrpc.ErrMessageTooLarge},
+		{"newline unlimited", jsonrpc.NewlineFraming, 0, long + "\n", nil},
+		{"content length", jsonrpc.ContentLengthFraming, len(long), fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(long), long), nil},
+		{"content length too large", jsonrpc.ContentLengthFraming, len(long) - 1, fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(long), long), jsonrpc.ErrMessageTooLarge},
+		{"content length default", jsonrpc.ContentLengthFraming, -1, "Content-Length: 2147483647\r\n\r\n{}", jsonrpc.ErrMessageTooLarge},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			options := []jsonrpc.ServerOption{jsonrpc.ServerFraming(tc.framing)}
+			if tc.max >= 0 {
+				options = append(options, jsonrpc.ServerMaxMessageSize(tc.max))
+			}
+			var out bytes.Buffer
+			sut := jsonrpc.NewServer(addEndpoint(), options...)
+			if want, have := tc.want, sut.ServeConn(context.Background(), readWriter{strings.NewReader(tc.in), &out}); want != have {
+				t.Fatalf("want %v, have %v", want, have)
+			}
+			if tc.want == nil && !strings.Contains(out.String(), `"result":3`) {
+				t.Errorf("want the message answered, have %q", out.String())
+			}
+		})
+	}
+}
+
+func TestServerConnConcurrency(t *testing.T) {
+	var active, maxActive int32
+	ecm := jsonrpc.EndpointCodecMap{
+		"sleep": jsonrpc.EndpointCodec{
+			Endpoint: func(context.Context, interface{}) (interface{}, error) {
+				n := atomic.AddInt32(&active, 1)
+				defer atomic.AddInt32(&active, -1)
+				for {
+					m := atomic.LoadInt32(&maxActive)
+					if n <= m || atomic.CompareAndSwapInt32(&maxActive, m, n) {
+						break
+					}
+				}
+				time.Sleep(20 * time.Millisecond)
+				return nil, nil
+			},
+			Decode: func(context.Context, json.RawMessage) (interface{}, error) { return nil, nil },
+			Encode: func(_ context.Context, res interface{}) (json.RawMessage, error) { return json.Marshal(res) },
+		},
+	}
+
+	var in strings.Builder
+	for i := 0; i < 12; i++ {
+		fmt.Fprintf(&in, `{"jsonrpc": "2.0", "method": "sleep", "id": %d}`+"\n", i)
+	}
+	var out bytes.Buffer
+	sut := jsonrpc.NewServer(ecm, jsonrpc.ServerConnConcurrency(3))
+	if err := sut.ServeConn(context.Background(), readWriter{strings.NewReader(in.String()), &out}); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 12, strings.Count(out.String(), "\n"); want != have {
+		t.Errorf("want %d responses, have %d", want, have)
+	}
+	if want, have := int32(3), atomic.LoadInt32(&maxActive); want != have {
+		t.Errorf("want %d messages handled concurrently, have %d", want, have)
+	}
+}
+
+func TestServerServe(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jsonrpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, tc := range []struct {
+		network string
+		address string
+	}{
+		{"tcp", "127.0.0.1:0"},
+		{"unix", filepath.Join(dir, "jsonrpc.sock")},
+	} {
+		t.Run(tc.network, func(t *testing.T) {
+			l, err := net.Listen(tc.network, tc.address)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sut := jsonrpc.NewServer(addEndpoint())
+			done := make(chan error)
+			go func() { done <- sut.Serve(l) }()
+
+			conn, err := net.Dial(tc.network, l.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			if _, err := io.WriteString(conn, `{"jsonrpc": "2.0", "method": "add", "params": [20, 22], "id": 1}`+"\n"); err != nil {
+				t.Fatal(err)
+			}
+			res, err := jsonrpc.NewlineFraming.ReadMessage(bufio.NewReader(conn), 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want, have := `{"jsonrpc":"2.0","result":42,"id":1}`, string(res); want != have {
+				t.Errorf("want %s, have %s", want, have)
+			}
+
+			// Closing the listener closes the served connections.
+			l.Close()
+			if err := <-done; err == nil {
+				t.Error("want error from Serve, have none")
+			}
+			if _, err := conn.Read(make([]byte, 1)); err == nil {
+				t.Error("want connection closed")
+			}
+		})
+	}
+}
+
+// flakyListener fails its first Accepts with a net.Error.
+type flakyListener struct {
+	net.Listener
+	fails int32
+}
+
+func (l *flakyListener) Accept() (net.Conn, error) {
+	if atomic.AddInt32(&l.fails, -1) >= 0 {
+		return nil, &net.OpError{Op: "accept", Net: "tcp", Err: os.NewSyscallError("accept", syscall.EMFILE)}
+	}
+	return l.Listener.Accept()
+}
+
+func TestServerServeRetriesAccept(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &flakyListener{Listener: inner, fails: 3}
+	sut := jsonrpc.NewServer(addEndpoint())
+	done := make(chan error)
+	go func() { done <- sut.Serve(l) }()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := io.WriteString(conn, `{"jsonrpc": "2.0", "method": "add", "params": [1, 2], "id": 1}`+"\n"); err != nil {
+		t.Fatal(err)
+	}
+	res, err := jsonrpc.NewlineFraming.ReadMessage(bufio.NewReader(conn), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := `{"jsonrpc":"2.0","result":3,"id":1}`, string(res); want != have {
+		t.Errorf("want %s, have %s", want, have)
+	}
+
+	l.Close()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("want error from Serve, have none")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Serve did not return once the listener was closed")
+	}
+}
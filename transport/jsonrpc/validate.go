@@ -0,0 +1,118 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// IsBatchMessage reports whether data holds a batch, an array of requests,
+// rather than a single request.
+func IsBatchMessage(data []byte) bool {
+	data = bytes.TrimLeft(data, " \t\r\n")
+	return len(data) > 0 && data[0] == '['
+}
+
+// Decode decodes the requests held by data with codec, and reports whether
+// data holds a batch. A single request is returned as a batch of one.
+// If strict is set, each request is validated with DecodeStrict. The
+// returned error implements ErrorCoder.
+func Decode(codec Codec, data []byte, strict bool) (reqs []Request, invalid []error, batch bool, err error) {
+	batch = IsBatchMessage(data)
+	if strict {
+		reqs, invalid, err = DecodeStrict(codec, data, batch)
+		return reqs, invalid, batch, err
+	}
+	if batch {
+		err = codec.Unmarshal(data, &reqs)
+	} else {
+		reqs = make([]Request, 1)
+		err = codec.Unmarshal(data, &reqs[0])
+	}
+	if err != nil {
+		return nil, nil, batch, NewError(ParseError, "JSON could not be decoded: "+err.Error())
+	}
+	return reqs, nil, batch, nil
+}
+
+// DecodeStrict decodes the requests held by data and validates each of them
+// against the spec: the jsonrpc member must be "2.0", the method a non-empty
+// string, the params, if any, an array or an object, and the id a string, a
+// number or null. The error of an invalid request is returned at its index
+// in invalid; the request itself only holds its ID, if it could be read.
+// An empty batch is rejected as a whole with an invalid request error.
+func DecodeStrict(codec Codec, data []byte, batch bool) (reqs []Request, invalid []error, err error) {
+	raws := []json.RawMessage{data}
+	if batch {
+		if err := json.Unmarshal(data, &raws); err != nil {
+			return nil, nil, NewError(ParseError, "JSON could not be decoded: "+err.Error())
+		}
+		if len(raws) == 0 {
+			return nil, nil, NewError(InvalidRequestError, "Batch must hold at least one request.")
+		}
+	} else if !json.Valid(data) {
+		return nil, nil, NewError(ParseError, "JSON could not be decoded.")
+	}
+
+	reqs = make([]Request, len(raws))
+	invalid = make([]error, len(raws))
+	for i, raw := range raws {
+		reqs[i], invalid[i] = validateRequest(codec, raw)
+	}
+	return reqs, invalid, nil
+}
+
+// validateRequest decodes raw into a Request if it is a valid request object
+// as defined by http://www.jsonrpc.org/specification#request_object
+func validateRequest(codec Codec, raw json.RawMessage) (req Request, err error) {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &members); err != nil || members == nil {
+		return req, NewError(InvalidRequestError, "Request must be an object.")
+	}
+
+	if id, ok := members["id"]; ok {
+		if !isValidID(id) {
+			return req, NewError(InvalidRequestError, "Request id must be a string, a number or null.")
+		}
+		req.ID = new(RequestID)
+		_ = req.ID.UnmarshalJSON(id)
+	}
+
+	var version string
+	if err := json.Unmarshal(members["jsonrpc"], &version); err != nil || version != Version {
+		return req, NewError(InvalidRequestError, `Request jsonrpc member must be exactly "`+Version+`".`)
+	}
+
+	var method string
+	if err := json.Unmarshal(members["method"], &method); err != nil || method == "" {
+		return req, NewError(InvalidRequestError, "Request method must be a non-empty string.")
+	}
+
+	if params, ok := members["params"]; ok {
+		if c := firstByte(params); c != '[' && c != '{' {
+			return req, NewError(InvalidRequestError, "Request params must be an array or an object.")
+		}
+	}
+
+	if err := codec.Unmarshal(raw, &req); err != nil {
+		return req, NewError(InvalidRequestError, err.Error())
+	}
+	return req, nil
+}
+
+// isValidID reports whether id is a JSON string, number or null.
+func isValidID(id json.RawMessage) bool {
+	switch c := firstByte(id); {
+	case c == '"', c == '-', c >= '0' && c <= '9':
+		return true
+	default:
+		return bytes.Equal(bytes.TrimSpace(id), []byte("null"))
+	}
+}
+
+func firstByte(data []byte) byte {
+	data = bytes.TrimLeft(data, " \t\r\n")
+	if len(data) == 0 {
+		return 0
+	}
+	return data[0]
+}